@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"gta_save/save"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Parses a script variable value. Integers (in decimal, or in hexadecimal with a "0x" prefix)
+// are stored as they are, and anything else that parses as a number (e.g. "1.0" or "2.5") is
+// stored as a float.
+func parseVariableValue(str string) (uint32, error) {
+	if integer, err := save.ParseInteger(str, 32); err == nil {
+		return save.IntValue(int32(integer)), nil
+	}
+
+	floating, err := strconv.ParseFloat(str, 32)
+
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not an integer or a float", str)
+	}
+
+	return save.FloatValue(float32(floating)), nil
+}
+
+// A set of `<index>=<value>` flags, used for giving initial values to script variables.
+type variableValues map[int]uint32
+
+func (values variableValues) String() string {
+	parts := make([]string, 0, len(values))
+
+	for index, value := range values {
+		parts = append(parts, fmt.Sprintf("%d=%d", index, value))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (values variableValues) Set(str string) error {
+	equalsIndex := strings.IndexRune(str, '=')
+
+	if equalsIndex < 0 {
+		return fmt.Errorf("expected '<index>=<value>', got '%s'", str)
+	}
+
+	index, err := strconv.Atoi(str[:equalsIndex])
+
+	if err != nil {
+		return fmt.Errorf("bad variable index '%s'", str[:equalsIndex])
+	}
+
+	value, err := parseVariableValue(str[equalsIndex+1:])
+
+	if err != nil {
+		return err
+	}
+
+	values[index] = value
+	return nil
+}
+
+// A list of `<path>=<value>` flags, used for setting fields of a save by path.
+type fieldAssignments []string
+
+func (assignments *fieldAssignments) String() string {
+	return strings.Join(*assignments, ",")
+}
+
+func (assignments *fieldAssignments) Set(str string) error {
+	if _, _, err := splitAssignment(str); err != nil {
+		return err
+	}
+
+	*assignments = append(*assignments, str)
+	return nil
+}
+
+// A list of file paths, used for embedding files as data. The files are read as the flags
+// are parsed. Each blob is known by its number (its position in the list), and the file's
+// name is only used in messages.
+type dataBlobs []dataBlob
+
+func (blobs *dataBlobs) String() string {
+	names := make([]string, len(*blobs))
+
+	for i, blob := range *blobs {
+		names[i] = blob.name
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (blobs *dataBlobs) Set(str string) error {
+	contents, err := os.ReadFile(str)
+
+	if err != nil {
+		return err
+	}
+
+	*blobs = append(*blobs, dataBlob{name: filepath.Base(str), contents: contents})
+	return nil
+}
+
+// Parses a position in global space, given either as a byte offset or as a global index in
+// the form "$<index>".
+func parseGlobalPosition(str string) (uint32, error) {
+	if strings.HasPrefix(str, "$") {
+		index, err := save.ParseUnsigned(str[1:], 32)
+
+		if err != nil || index > math.MaxUint32/4 {
+			return 0, fmt.Errorf("bad global index '%s'", str)
+		}
+
+		return uint32(index) * 4, nil
+	}
+
+	offset, err := save.ParseUnsigned(str, 32)
+
+	if err != nil {
+		return 0, fmt.Errorf("bad byte offset '%s'", str)
+	}
+
+	return uint32(offset), nil
+}
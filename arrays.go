@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+	"strconv"
+)
+
+var arrayKinds = map[string]bool{"swap": true, "hide": true, "suppress": true, "lod": true, "assign": true}
+
+var entityTypes = map[string]save.EntityType{
+	"building": save.EntityBuilding,
+	"object":   save.EntityObject,
+	"dummy":    save.EntityDummy,
+}
+
+func listArrays(saveFile *save.File, models *modelNames) {
+	scripts := &saveFile.Scripts
+	arrays := &scripts.Arrays
+
+	slots := scripts.ModelSwapSlots()
+	fmt.Printf("Model swaps (%d of %d):\n", len(slots), len(arrays.StaticReplacements))
+
+	for _, slot := range slots {
+		swap := &arrays.StaticReplacements[slot]
+
+		fmt.Printf("  %2d: %s %d, %s -> %s\n", slot, save.EntityType(swap.Type).ToString(), swap.Handle,
+			models.describe(swap.OldModelId), models.describe(swap.NewModelId))
+	}
+
+	slots = scripts.HiddenObjectSlots()
+	fmt.Printf("Hidden objects (%d of %d):\n", len(slots), len(arrays.InvisibleObjects))
+
+	for _, slot := range slots {
+		object := &arrays.InvisibleObjects[slot]
+		fmt.Printf("  %2d: %s %d\n", slot, save.EntityType(object.Type).ToString(), object.Handle)
+	}
+
+	slots = scripts.SuppressedModelSlots()
+	fmt.Printf("Suppressed vehicle models (%d of %d):\n", len(slots), len(arrays.SuppressedVehicleModels))
+
+	for _, slot := range slots {
+		fmt.Printf("  %2d: %s\n", slot, models.describe(int32(arrays.SuppressedVehicleModels[slot])))
+	}
+
+	slots = scripts.LodAssignmentSlots()
+	fmt.Printf("LOD assignments (%d of %d):\n", len(slots), len(arrays.LodAssignments))
+
+	for _, slot := range slots {
+		assignment := &arrays.LodAssignments[slot]
+		fmt.Printf("  %2d: object %d uses LOD %d\n", slot, assignment.ObjectHandle, assignment.LodHandle)
+	}
+
+	slots = scripts.ScriptAssignmentSlots()
+	fmt.Printf("Script assignments (%d of %d):\n", len(slots), len(arrays.ScriptAssignments))
+
+	for _, slot := range slots {
+		assignment := &arrays.ScriptAssignments[slot]
+		fmt.Printf("  %2d: %s -> '%s'\n", slot, models.describe(int32(assignment.ActorModelId)), assignment.Name())
+	}
+}
+
+// Adds an entry to the array called `kind`, using the values given as flags.
+func addArrayEntry(kind string, arguments []string, usage func()) {
+	flags := flag.NewFlagSet(kind, flag.ExitOnError)
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+
+	models := newModelNames()
+	flags.Func("ide", "load model names from an .ide `file` (repeatable)", models.load)
+
+	entityTypeName := flags.String("type", "building", "entity type for swaps and hidden objects (building, object or dummy)")
+	handle := flags.Uint("handle", 0, "handle of the entity to swap or hide")
+	oldModel := flags.String("old", "", "model that is being swapped out")
+	newModel := flags.String("new", "", "model to swap in")
+	model := flags.String("model", "", "vehicle model to suppress, or actor model for a script assignment")
+	objectHandle := flags.Uint("object", 0, "handle of the object to assign a LOD to")
+	lodHandle := flags.Uint("lod", 0, "handle of the LOD object")
+	name := flags.String("name", "", "name for a script assignment")
+
+	flags.Parse(arguments)
+	positional := flags.Args()
+
+	if len(positional) != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	parseModel := func(str string) int32 {
+		id, err := models.parse(str)
+
+		if err != nil {
+			fmt.Printf("Bad model: %v\n", err)
+			os.Exit(1)
+		}
+
+		return id
+	}
+
+	entityType, found := entityTypes[*entityTypeName]
+
+	if !found {
+		fmt.Printf("Unknown entity type '%s'.\n", *entityTypeName)
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(positional[0])
+	scripts := &saveFile.Scripts
+
+	var slot int
+	var err error
+
+	switch kind {
+	case "swap":
+		slot, err = scripts.AddModelSwap(entityType, uint32(*handle), parseModel(*oldModel), parseModel(*newModel))
+	case "hide":
+		slot, err = scripts.AddHiddenObject(entityType, uint32(*handle))
+	case "suppress":
+		slot, err = scripts.AddSuppressedModel(parseModel(*model))
+	case "lod":
+		slot, err = scripts.AddLodAssignment(uint32(*objectHandle), uint32(*lodHandle))
+	case "assign":
+		slot, err = scripts.AddScriptAssignment(parseModel(*model), *name)
+	}
+
+	if err != nil {
+		fmt.Printf("Unable to add %s: %v\n", kind, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Added %s in slot %d.\n", kind, slot)
+	writeSave(positional[1], &saveFile)
+}
+
+func removeArrayEntry(kind string, arguments []string, usage func()) {
+	if len(arguments) != 3 {
+		usage()
+		os.Exit(1)
+	}
+
+	slot, err := strconv.Atoi(arguments[2])
+
+	if err != nil {
+		fmt.Printf("Bad slot '%s'.\n", arguments[2])
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(arguments[0])
+	scripts := &saveFile.Scripts
+
+	removers := map[string]func(int) error{
+		"swap":     scripts.RemoveModelSwap,
+		"hide":     scripts.RemoveHiddenObject,
+		"suppress": scripts.RemoveSuppressedModel,
+		"lod":      scripts.RemoveLodAssignment,
+		"assign":   scripts.RemoveScriptAssignment,
+	}
+
+	if err := removers[kind](slot); err != nil {
+		fmt.Printf("Unable to remove %s: %v\n", kind, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Removed %s in slot %d.\n", kind, slot)
+	writeSave(arguments[1], &saveFile)
+}
+
+func arraysCommand(name string, arguments []string) {
+	usage := func() {
+		fmt.Printf("Usage: '%s list [-ide <file>] <save>'\n", name)
+		fmt.Printf("   or: '%s add <kind> [options] <input save> <output save>'\n", name)
+		fmt.Printf("   or: '%s remove <kind> <input save> <output save> <slot>'\n", name)
+		fmt.Println("\nKinds are 'swap', 'hide', 'suppress', 'lod' and 'assign'.")
+	}
+
+	if len(arguments) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch arguments[0] {
+	case "list":
+		flags := flag.NewFlagSet(name+" list", flag.ExitOnError)
+
+		models := newModelNames()
+		flags.Func("ide", "load model names from an .ide `file` (repeatable)", models.load)
+
+		flags.Parse(arguments[1:])
+
+		if flags.NArg() != 1 {
+			usage()
+			os.Exit(1)
+		}
+
+		saveFile := loadSave(flags.Arg(0))
+		listArrays(&saveFile, models)
+
+	case "add":
+		if len(arguments) < 2 || !arrayKinds[arguments[1]] {
+			usage()
+			os.Exit(1)
+		}
+
+		addArrayEntry(arguments[1], arguments[2:], usage)
+
+	case "remove":
+		if len(arguments) < 2 || !arrayKinds[arguments[1]] {
+			usage()
+			os.Exit(1)
+		}
+
+		removeArrayEntry(arguments[1], arguments[2:], usage)
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"math"
+	"os"
+	"strconv"
+)
+
+func listBrains(saveFile *save.File) {
+	scripts := &saveFile.Scripts
+	slots := scripts.UsedBrainSlots()
+
+	fmt.Printf("%d of %d brain slots in use.\n", len(slots), len(scripts.Brains))
+
+	for _, slot := range slots {
+		theBrain := &scripts.Brains[slot]
+
+		fmt.Printf("%2d: script %d, %s", slot, theBrain.General.Index, theBrain.AttachTypeName())
+
+		if theBrain.IsPedBrain() || theBrain.IsObjectBrain() {
+			fmt.Printf(" model %d, %d%% chance, radius %.1f\n",
+				theBrain.PedOrObject.ModelId, theBrain.PedOrObject.ActivationChance, theBrain.General.Radius)
+		} else {
+			fmt.Printf(" '%s'\n", theBrain.ScriptName)
+		}
+	}
+}
+
+// The flags used to describe a new brain.
+type brainFlags struct {
+	scriptIndex   *uint
+	pedModel      *int
+	objectModel   *int
+	codeName      *string
+	attractorName *string
+	chance        *uint
+	radius        *float64
+}
+
+func addBrainFlags(flags *flag.FlagSet) brainFlags {
+	return brainFlags{
+		scriptIndex:   flags.Uint("script", 0, "index of the streamed script (in script.img) that the brain starts"),
+		pedModel:      flags.Int("ped", -1, "start the script when a ped with this model streams in"),
+		objectModel:   flags.Int("object", -1, "start the script when an object with this model streams in"),
+		codeName:      flags.String("code", "", "start the script when code asks for this name"),
+		attractorName: flags.String("attractor", "", "start the script when an attractor asks for this name"),
+		chance:        flags.Uint("chance", 100, "percentage chance of a ped or object brain starting"),
+		radius:        flags.Float64("radius", 5, "distance from the player within which a ped or object brain can start"),
+	}
+}
+
+// Creates the brain described by the flags and stores it in `slot`, or in the first free
+// slot if `slot` is negative. Returns the slot used.
+func (brainFlags *brainFlags) store(saveFile *save.File, slot int) (int, error) {
+	kindCount := 0
+
+	for _, isSet := range []bool{*brainFlags.pedModel >= 0, *brainFlags.objectModel >= 0, *brainFlags.codeName != "", *brainFlags.attractorName != ""} {
+		if isSet {
+			kindCount++
+		}
+	}
+
+	if kindCount != 1 {
+		return slot, errors.New("exactly one of -ped, -object, -code or -attractor must be given")
+	}
+
+	// Check the values before they're cut down to the sizes that brains store.
+	if *brainFlags.scriptIndex > math.MaxUint16 {
+		return slot, fmt.Errorf("streamed script index %d is too large", *brainFlags.scriptIndex)
+	}
+
+	if *brainFlags.pedModel > math.MaxUint16 || *brainFlags.objectModel > math.MaxUint16 {
+		return slot, fmt.Errorf("model IDs must be at most %d", math.MaxUint16)
+	}
+
+	if *brainFlags.chance > 100 {
+		return slot, fmt.Errorf("chance %d%% is more than 100%%", *brainFlags.chance)
+	}
+
+	scriptIndex := uint16(*brainFlags.scriptIndex)
+	chance := uint16(*brainFlags.chance)
+	radius := float32(*brainFlags.radius)
+
+	// Start with a code brain, and replace it if a different kind was asked for.
+	theBrain, err := save.NewNamedBrain(false, scriptIndex, *brainFlags.codeName)
+
+	if *brainFlags.pedModel >= 0 {
+		theBrain, err = save.NewModelBrain(false, scriptIndex, uint16(*brainFlags.pedModel), chance, radius)
+	} else if *brainFlags.objectModel >= 0 {
+		theBrain, err = save.NewModelBrain(true, scriptIndex, uint16(*brainFlags.objectModel), chance, radius)
+	} else if *brainFlags.attractorName != "" {
+		theBrain, err = save.NewNamedBrain(true, scriptIndex, *brainFlags.attractorName)
+	}
+
+	if err != nil {
+		return slot, err
+	}
+
+	if slot < 0 {
+		return saveFile.Scripts.AddBrain(theBrain)
+	}
+
+	return slot, saveFile.Scripts.SetBrain(slot, theBrain)
+}
+
+func brainsCommand(name string, arguments []string) {
+	usage := func() {
+		fmt.Printf("Usage: '%s list <save>'\n", name)
+		fmt.Printf("   or: '%s add [options] <input save> <output save>'\n", name)
+		fmt.Printf("   or: '%s set [options] <input save> <output save> <slot>'\n", name)
+		fmt.Printf("   or: '%s remove <input save> <output save> <slot>'\n", name)
+	}
+
+	if len(arguments) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	action := arguments[0]
+	flags := flag.NewFlagSet(name+" "+action, flag.ExitOnError)
+	newBrain := addBrainFlags(flags)
+
+	flags.Usage = func() {
+		usage()
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments[1:])
+	positional := flags.Args()
+
+	if action == "list" {
+		if len(positional) != 1 {
+			flags.Usage()
+			os.Exit(1)
+		}
+
+		saveFile := loadSave(positional[0])
+		listBrains(&saveFile)
+
+		return
+	}
+
+	expectedCount := map[string]int{"add": 2, "set": 3, "remove": 3}[action]
+
+	if expectedCount == 0 || len(positional) != expectedCount {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	slot := -1
+
+	if expectedCount == 3 {
+		parsed, err := strconv.Atoi(positional[2])
+
+		if err != nil {
+			fmt.Printf("Bad slot '%s'.\n", positional[2])
+			os.Exit(1)
+		}
+
+		slot = parsed
+	}
+
+	saveFile := loadSave(positional[0])
+
+	var err error
+
+	if action == "remove" {
+		err = saveFile.Scripts.RemoveBrain(slot)
+	} else {
+		slot, err = newBrain.store(&saveFile, slot)
+	}
+
+	if err != nil {
+		fmt.Printf("Unable to %s brain: %v\n", action, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Updated brain slot %d.\n", slot)
+	writeSave(positional[1], &saveFile)
+}
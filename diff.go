@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+)
+
+func diffCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	asJson := flags.Bool("json", false, "print the differences as JSON")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <old save> <new save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	oldSave, newSave := readSave(flags.Arg(0)), readSave(flags.Arg(1))
+
+	if oldSave.Platform != newSave.Platform {
+		fmt.Fprintf(os.Stderr, "Warning: The saves are from different platforms (%s and %s).\n",
+			oldSave.Platform.ToString(), newSave.Platform.ToString())
+	}
+
+	changes := save.Diff(&oldSave, &newSave)
+
+	if *asJson {
+		encoded, err := json.MarshalIndent(changes, "", "  ")
+
+		if err != nil {
+			fmt.Printf("Unable to convert the differences to JSON: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(encoded))
+		return
+	}
+
+	for _, change := range changes {
+		if change.NewPath != "" {
+			fmt.Printf("%s (%s in the new save): %s -> %s\n", change.Path, change.NewPath, change.Old, change.New)
+			continue
+		}
+
+		fmt.Printf("%s: %s -> %s\n", change.Path, change.Old, change.New)
+	}
+
+	fmt.Printf("%d differences.\n", len(changes))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+)
+
+func dumpCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Bool("json", true, "write the save as JSON (the only format, so this can be left out)")
+	outputPath := flags.String("o", "", "write to this file instead of the terminal")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := readSave(flags.Arg(0))
+	writeJSON(*outputPath, &saveFile, "the save")
+}
+
+// Writes `value` as indented JSON to the file at `path`, or to the terminal if `path` is
+// empty. `description` names the value in error messages. Errors go to stderr so that they
+// don't end up mixed in with JSON on the terminal.
+func writeJSON(path string, value interface{}, description string) {
+	encoded, err := json.Marshal(value)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to convert %s to JSON: %v\n", description, err)
+		os.Exit(1)
+	}
+
+	indented := bytes.Buffer{}
+	json.Indent(&indented, encoded, "", "  ")
+	indented.WriteByte('\n')
+
+	if path == "" {
+		os.Stdout.Write(indented.Bytes())
+		return
+	}
+
+	if err := os.WriteFile(path, indented.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write '%s': %v\n", path, err)
+		os.Exit(1)
+	}
+}
+
+func loadCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Bool("json", true, "read the save as JSON (the only format, so this can be left out)")
+	relink := flags.Bool("relink", false, "rebuild the running script links from the order of the scripts (after adding, removing or reordering them)")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <JSON file> <output save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	encoded, err := os.ReadFile(flags.Arg(0))
+
+	if err != nil {
+		fmt.Printf("Unable to read '%s': %v\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+
+	saveFile := save.File{}
+
+	if err := json.Unmarshal(encoded, &saveFile); err != nil {
+		fmt.Printf("Unable to load '%s': %v\n", flags.Arg(0), err)
+		os.Exit(1)
+	}
+
+	if *relink {
+		saveFile.Scripts.AllowRelinkingCorruptList()
+
+		if err := saveFile.Scripts.Relink(); err != nil {
+			fmt.Printf("Unable to relink the running scripts: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := saveFile.Scripts.ValidateLinks(); err != nil {
+		fmt.Printf("Warning: The running script list is corrupt: %v\n", err)
+	}
+
+	for _, problem := range saveFile.Scripts.CheckValues() {
+		fmt.Printf("Warning: %s.\n", problem)
+	}
+
+	writeSave(flags.Arg(1), &saveFile)
+}
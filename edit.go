@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+	"time"
+)
+
+// Parses a time of day given as "<hour>:<minute>".
+func parseTimeOfDay(str string) (int, int, error) {
+	var hour, minute int
+
+	if count, err := fmt.Sscanf(str, "%d:%d", &hour, &minute); err != nil || count != 2 {
+		return 0, 0, fmt.Errorf("expected '<hour>:<minute>', got '%s'", str)
+	}
+
+	return hour, minute, nil
+}
+
+// Parses a real time given as "now" or as "<year>-<month>-<day> <hour>:<minute>[:<second>]"
+// in local time.
+func parseSystemTime(str string) (time.Time, error) {
+	if str == "now" {
+		return time.Now(), nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if when, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return when, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("expected 'now' or '<year>-<month>-<day> <hour>:<minute>', got '%s'", str)
+}
+
+func editCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	timeOfDay := flags.String("time", "", "time of day, as `<hour>:<minute>`")
+	weekday := flags.String("weekday", "", "day of the week, as a name or a `number` from 1 (Sunday) to 7 (Saturday)")
+	weather := flags.Int("weather", 0, "force a weather type (0 to 22), or -1 to stop forcing the weather")
+	rain := flags.Float64("rain", 0, "how heavy the rain is, from 0 to 1")
+	riot := flags.Bool("riot", false, "whether riot mode is on")
+	maxWanted := flags.Int("max-wanted", 0, "maximum wanted level (0 to 6)")
+	extraColor := flags.Int("extra-colour", 0, "turn on a timecycle extra colour (0 to 15), or -1 to turn it off")
+	invertLook := flags.Bool("invert-look", false, "whether looking up and down is inverted")
+	taxiNitro := flags.Bool("taxi-nitro", false, "whether the taxi nitro cheat is on")
+	prostitutesPay := flags.Bool("prostitutes-pay", false, "whether the prostitutes pay you cheat is on")
+
+	title := flags.String("title", "", "the save's title in the load menu (normally the last mission passed)")
+	truncateTitle := flags.Bool("truncate-title", false, "cut the title down to fit instead of failing if it's too long")
+	systemTime := flags.String("system-time", "", "when the save was made (PC only), as `now` or '<year>-<month>-<day> <hour>:<minute>'")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <input save> <output save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 || flags.NFlag() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(flags.Arg(0))
+	vars := &saveFile.Vars
+
+	check := func(flagName string, err error) {
+		if err != nil {
+			fmt.Printf("Bad value for -%s: %v\n", flagName, err)
+			os.Exit(1)
+		}
+	}
+
+	// Only change the values that were given.
+	flags.Visit(func(given *flag.Flag) {
+		switch given.Name {
+		case "time":
+			hour, minute, err := parseTimeOfDay(*timeOfDay)
+
+			if err == nil {
+				err = vars.SetTime(hour, minute)
+			}
+
+			check(given.Name, err)
+
+		case "weekday":
+			day, err := save.ParseWeekday(*weekday)
+
+			if err == nil {
+				err = vars.SetWeekday(day)
+			}
+
+			check(given.Name, err)
+
+		case "weather":
+			check(given.Name, vars.SetForcedWeather(*weather))
+		case "rain":
+			check(given.Name, vars.SetRainHeaviness(float32(*rain)))
+		case "riot":
+			vars.Riots.Active = *riot
+		case "max-wanted":
+			check(given.Name, vars.SetMaxWantedLevel(*maxWanted))
+		case "extra-colour":
+			check(given.Name, vars.SetExtraColor(*extraColor))
+		case "invert-look":
+			vars.Surroundings.InvertLook = *invertLook
+		case "taxi-nitro":
+			vars.Cheats.TaxisHaveNitro = *taxiNitro
+		case "prostitutes-pay":
+			vars.Cheats.ProstitutesPayYou = *prostitutesPay
+
+		case "title":
+			policy := save.RejectLong
+
+			if *truncateTitle {
+				policy = save.TruncateLong
+			}
+
+			check(given.Name, vars.SetLastMissionPassed(&saveFile.Platform, *title, policy))
+
+		case "truncate-title":
+			return
+
+		case "system-time":
+			when, err := parseSystemTime(*systemTime)
+
+			if err == nil {
+				err = vars.SetSystemTime(&saveFile.Platform, when)
+			}
+
+			check(given.Name, err)
+		}
+
+		fmt.Printf("Set %s.\n", given.Name)
+	})
+
+	writeSave(flags.Arg(1), &saveFile)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Adds the -symbols flag, which loads global names from CustomVariables.ini or CSV files.
+func addSymbolsFlag(flags *flag.FlagSet, symbols *save.SymbolMap) {
+	flags.Func("symbols", "load global names from a Sanny Builder `CustomVariables.ini` or a CSV file (repeatable)", func(path string) error {
+		return symbols.Load(path)
+	})
+}
+
+// Names a global as "NAME ($index)", or just "$index" if it doesn't have a name.
+func describeGlobalName(symbols *save.SymbolMap, symbol save.GlobalSymbol) string {
+	if _, found := symbols.AtIndex(symbol.Index); found {
+		return fmt.Sprintf("%s ($%d)", symbol.Name, symbol.Index)
+	}
+
+	return fmt.Sprintf("$%d", symbol.Index)
+}
+
+// Formats a global's value. Globals without a symbol are shown as both an integer and a
+// float, since we can't tell which they are.
+func formatGlobalValue(symbols *save.SymbolMap, symbol save.GlobalSymbol, value uint32) string {
+	if _, found := symbols.AtIndex(symbol.Index); found {
+		return symbol.Format(value)
+	}
+
+	return formatVariable(value)
+}
+
+func describeGlobal(symbols *save.SymbolMap, symbol save.GlobalSymbol, value uint32) string {
+	return describeGlobalName(symbols, symbol) + " = " + formatGlobalValue(symbols, symbol, value)
+}
+
+func getGlobals(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	symbols := save.NewSymbolMap()
+	addSymbolsFlag(flags, symbols)
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <save> <name or index>...'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(flags.Arg(0))
+
+	for _, nameOrIndex := range flags.Args()[1:] {
+		symbol, value, err := saveFile.Scripts.NamedGlobal(symbols, nameOrIndex)
+
+		if err != nil {
+			fmt.Printf("Unable to read '%s': %v\n", nameOrIndex, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(describeGlobal(symbols, symbol, value))
+	}
+}
+
+func setGlobals(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	symbols := save.NewSymbolMap()
+	addSymbolsFlag(flags, symbols)
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <input save> <output save> <name or index>=<value>...'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() < 3 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(flags.Arg(0))
+
+	for _, assignment := range flags.Args()[2:] {
+		equalsIndex := strings.IndexRune(assignment, '=')
+
+		if equalsIndex < 0 {
+			fmt.Printf("Expected '<name or index>=<value>', got '%s'.\n", assignment)
+			os.Exit(1)
+		}
+
+		nameOrIndex, valueString := assignment[:equalsIndex], assignment[equalsIndex+1:]
+		symbol, err := symbols.Resolve(nameOrIndex)
+
+		if err != nil {
+			fmt.Printf("Unable to set '%s': %v\n", nameOrIndex, err)
+			os.Exit(1)
+		}
+
+		var value uint32
+
+		// Globals without a symbol could be either type, so guess from the value.
+		if _, found := symbols.AtIndex(symbol.Index); found {
+			value, err = symbol.Parse(valueString)
+		} else {
+			value, err = parseVariableValue(valueString)
+		}
+
+		if err == nil {
+			err = saveFile.Scripts.SetGlobal(symbol.Index, value)
+		}
+
+		if err != nil {
+			fmt.Printf("Unable to set '%s': %v\n", nameOrIndex, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(describeGlobal(symbols, symbol, value))
+	}
+
+	writeSave(flags.Arg(1), &saveFile)
+}
+
+// A global as it appears in a dump.
+type dumpedGlobal struct {
+	Index int    `json:"index"`
+	Raw   uint32 `json:"raw"`
+	Int   int32  `json:"int"`
+
+	// JSON can't represent NaN or infinity, so the float is left out for those values.
+	Float *float32 `json:"float,omitempty"`
+	Name  string   `json:"name,omitempty"`
+}
+
+func writeGlobalsCsv(writer io.Writer, globals []dumpedGlobal) error {
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Write([]string{"index", "raw", "int", "float", "name"})
+
+	for _, global := range globals {
+		floatString := ""
+
+		if global.Float != nil {
+			floatString = strconv.FormatFloat(float64(*global.Float), 'g', -1, 32)
+		}
+
+		csvWriter.Write([]string{
+			strconv.Itoa(global.Index),
+			fmt.Sprintf("0x%08x", global.Raw),
+			strconv.Itoa(int(global.Int)),
+			floatString,
+			global.Name,
+		})
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func dumpGlobals(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	symbols := save.NewSymbolMap()
+	addSymbolsFlag(flags, symbols)
+
+	format := flags.String("format", "csv", "output format (`csv` or `json`)")
+	outputPath := flags.String("o", "", "write the dump to this file instead of the terminal")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 1 || (*format != "csv" && *format != "json") {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := readSave(flags.Arg(0))
+	globals := []dumpedGlobal{}
+
+	for index, value := range saveFile.Scripts.GlobalVariables() {
+		global := dumpedGlobal{Index: index, Raw: value, Int: int32(value)}
+
+		if floating := math.Float32frombits(value); !math.IsNaN(float64(floating)) && !math.IsInf(float64(floating), 0) {
+			global.Float = &floating
+		}
+
+		if symbol, found := symbols.AtIndex(index); found {
+			global.Name = symbol.Name
+		}
+
+		globals = append(globals, global)
+	}
+
+	var writer io.Writer = os.Stdout
+
+	if *outputPath != "" {
+		outputFile, err := os.Create(*outputPath)
+
+		if err != nil {
+			fmt.Printf("Unable to create '%s': %v\n", *outputPath, err)
+			os.Exit(1)
+		}
+
+		defer outputFile.Close()
+		writer = outputFile
+	}
+
+	var err error
+
+	if *format == "json" {
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(globals)
+	} else {
+		err = writeGlobalsCsv(writer, globals)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write the dump: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Adds the options that decide which globals are compared between saves.
+func addComparisonFlags(flags *flag.FlagSet) (*int, *string) {
+	ignoreFrom := flags.Int("ignore-from", math.MaxInt32, "also ignore this global and every one after it")
+	mainPath := flags.String("main", "", "the game's `main.scm`, so that the globals after the ones it uses are ignored")
+
+	return ignoreFrom, mainPath
+}
+
+// Works out which global to stop comparing at. Besides `ignoreFrom`, comparisons stop at the
+// first global holding code that a script in one of the saves is running, and (if main.scm
+// was given) after the globals that main.scm uses, since past those is usually embedded code.
+func comparisonLimit(ignoreFrom int, mainPath string, saveFiles []*save.File) int {
+	limit := ignoreFrom
+
+	// Globals that not every save has are never compared, so there's no need to mention
+	//  limits past them.
+	for _, saveFile := range saveFiles {
+		if count := len(saveFile.Scripts.GlobalVariables()); count < limit {
+			limit = count
+		}
+	}
+
+	for _, saveFile := range saveFiles {
+		if start := saveFile.Scripts.EmbeddedCodeStart(); start < limit {
+			fmt.Printf("Ignoring globals from $%d on, where a script in the save is running embedded code.\n", start)
+			limit = start
+		}
+	}
+
+	if mainPath == "" {
+		return limit
+	}
+
+	mainFile, err := readMainScm(mainPath)
+
+	if err != nil {
+		fmt.Printf("Unable to read main.scm: %v\n", err)
+		os.Exit(1)
+	}
+
+	usedSpace, err := mainFile.usedGlobalSpace()
+
+	if err != nil {
+		fmt.Printf("Warning: Only part of main.scm could be read, so it may use more globals than were found: %v\n", err)
+	}
+
+	if usedCount := int(usedSpace+3) / 4; usedCount < limit {
+		fmt.Printf("Ignoring globals from $%d on, which main.scm doesn't use.\n", usedCount)
+		limit = usedCount
+	}
+
+	return limit
+}
+
+func diffGlobals(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	symbols := save.NewSymbolMap()
+	addSymbolsFlag(flags, symbols)
+
+	ignoreFrom, mainPath := addComparisonFlags(flags)
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <old save> <new save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	oldSave, newSave := readSave(flags.Arg(0)), readSave(flags.Arg(1))
+	oldCount, newCount := len(oldSave.Scripts.GlobalVariables()), len(newSave.Scripts.GlobalVariables())
+
+	if oldCount != newCount {
+		comparedCount := oldCount
+
+		if newCount < comparedCount {
+			comparedCount = newCount
+		}
+
+		fmt.Printf("The saves have %d and %d globals, so only the first %d are compared.\n", oldCount, newCount, comparedCount)
+	}
+
+	limit := comparisonLimit(*ignoreFrom, *mainPath, []*save.File{&oldSave, &newSave})
+	changes := oldSave.Scripts.CompareGlobals(&newSave.Scripts, limit)
+
+	for _, change := range changes {
+		symbol, _ := symbols.Resolve(strconv.Itoa(change.Index))
+		fmt.Printf("%s: %s -> %s\n", describeGlobalName(symbols, symbol),
+			formatGlobalValue(symbols, symbol, change.Old), formatGlobalValue(symbols, symbol, change.New))
+	}
+
+	fmt.Printf("%d changed globals.\n", len(changes))
+}
+
+func globalsCommand(name string, arguments []string) {
+	usage := func() {
+		fmt.Printf("Usage: '%s get [options] <save> <name or index>...'\n", name)
+		fmt.Printf("   or: '%s set [options] <input save> <output save> <name or index>=<value>...'\n", name)
+		fmt.Printf("   or: '%s dump [options] <save>'\n", name)
+		fmt.Printf("   or: '%s diff [options] <old save> <new save>'\n", name)
+		fmt.Printf("   or: '%s timeline [options] <directory of saves>'\n", name)
+	}
+
+	if len(arguments) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch arguments[0] {
+	case "get":
+		getGlobals(name+" get", arguments[1:])
+
+	case "set":
+		setGlobals(name+" set", arguments[1:])
+
+	case "dump":
+		dumpGlobals(name+" dump", arguments[1:])
+
+	case "diff":
+		diffGlobals(name+" diff", arguments[1:])
+
+	case "timeline":
+		globalsTimeline(name+" timeline", arguments[1:])
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+)
+
+// A flag in the save that changes how the game plays.
+type saveFlag struct {
+	name        string
+	explanation string
+	field       func(saveFile *save.File) *bool
+}
+
+var saveFlags = []saveFlag{
+	{"cheated", "the game treats the playthrough as cheated and shows it in the stats",
+		func(saveFile *save.File) *bool { return &saveFile.Vars.Player.HasPlayerCheated }},
+	{"french", "the game makes the French release's cuts to violent content",
+		func(saveFile *save.File) *bool { return &saveFile.Vars.Audience.FrenchGame }},
+	{"german", "the game makes the German release's cuts, removing gore and some violent content",
+		func(saveFile *save.File) *bool { return &saveFile.Vars.Audience.GermanGame }},
+	{"uncensored", "the game shows gore, such as blood and dismemberment",
+		func(saveFile *save.File) *bool { return &saveFile.Vars.Audience.Uncensored }},
+}
+
+func onOrOff(value bool) string {
+	if value {
+		return "on"
+	}
+
+	return "off"
+}
+
+func printSaveFlags(saveFile *save.File) {
+	for _, saveFlag := range saveFlags {
+		fmt.Printf("  %-10s %-3s  (when on, %s)\n", saveFlag.name, onOrOff(*saveFlag.field(saveFile)), saveFlag.explanation)
+	}
+}
+
+func setSaveFlags(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	values := make([]*bool, len(saveFlags))
+
+	for i, saveFlag := range saveFlags {
+		values[i] = flags.Bool(saveFlag.name, false, "turn on, so that "+saveFlag.explanation)
+	}
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <input save> <output save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 2 || flags.NFlag() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(flags.Arg(0))
+
+	// Only change the flags that were given.
+	flags.Visit(func(given *flag.Flag) {
+		for i, saveFlag := range saveFlags {
+			if saveFlag.name == given.Name {
+				*saveFlag.field(&saveFile) = *values[i]
+				fmt.Printf("Turned %s %s.\n", saveFlag.name, onOrOff(*values[i]))
+			}
+		}
+	})
+
+	writeSave(flags.Arg(1), &saveFile)
+}
+
+func flagsCommand(name string, arguments []string) {
+	usage := func() {
+		fmt.Printf("Usage: '%s show <save>'\n", name)
+		fmt.Printf("   or: '%s set [options] <input save> <output save>'\n", name)
+	}
+
+	switch {
+	case len(arguments) == 2 && arguments[0] == "show":
+		saveFile := loadSave(arguments[1])
+		printSaveFlags(&saveFile)
+
+	case len(arguments) != 0 && arguments[0] == "set":
+		setSaveFlags(name+" set", arguments[1:])
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+func infoCommand(name string, arguments []string) {
+	if len(arguments) != 1 {
+		fmt.Printf("Usage: '%s <save>'\n", name)
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(arguments[0])
+	vars := &saveFile.Vars
+	scripts := &saveFile.Scripts
+
+	fmt.Printf("Title: %s\n", vars.Metadata.LastMissionPassed)
+
+	if saveFile.Platform.IsPC {
+		fmt.Printf("Saved at: %s\n", vars.SystemTime().Format("2006-01-02 15:04:05"))
+	}
+
+	clock := &vars.Clock.GameClock
+	fmt.Printf("Game clock: %s %02d:%02d (day %d of month %d)\n", vars.WeekdayName(), clock.Hour, clock.Minute, clock.DayOfMonth, clock.Month)
+	fmt.Printf("Game time: %d ms\n", vars.TimeMapping.TimeInMilliseconds)
+
+	if weather := vars.ForcedWeather(); weather == -1 {
+		fmt.Println("Forced weather: none")
+	} else {
+		fmt.Printf("Forced weather: %d\n", weather)
+	}
+
+	fmt.Printf("Maximum wanted level: %d\n", vars.WantedLevel.Maximum)
+	fmt.Printf("Riot: %s\n", onOrOff(vars.Riots.Active))
+	fmt.Printf("Cheats: taxi nitro %s, prostitutes pay %s\n", onOrOff(vars.Cheats.TaxisHaveNitro), onOrOff(vars.Cheats.ProstitutesPayYou))
+
+	fmt.Printf("Global space: %d bytes\n", scripts.GlobalByteCount())
+	fmt.Printf("Running scripts: %d\n", len(scripts.Running.RunningScripts))
+
+	if mission := scripts.MissionScript(); mission != nil {
+		fmt.Printf("Mission script: '%s'\n", mission.Name)
+	}
+
+	fmt.Println("Flags:")
+	printSaveFlags(&saveFile)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Squ1dd13/scm"
+)
+
+// Opcodes that take a variable number of arguments, ended by an end-of-arguments value.
+var variadicOpcodes = map[int]bool{
+	0x004f: true, // start_new_script
+	0x0913: true, // start_new_streamed_script
+	0x0a92: true, // start_new_custom_script
+	0x0a94: true, // launch_custom_mission
+	0x0aa5: true, // call_function
+	0x0aa6: true, // call_method
+	0x0aa7: true, // call_function_return
+	0x0aa8: true, // call_method_return
+	0x0ab1: true, // call
+	0x0ab2: true, // ret
+	0x0ace: true, // print_help_formatted
+	0x0acf: true, // print_big_formatted
+	0x0ad0: true, // print_formatted
+	0x0ad1: true, // print_formatted_now
+	0x0ad3: true, // string_format
+	0x0ad4: true, // scan_string
+	0x0ad9: true, // write_formatted_string_to_file
+	0x0ada: true, // scan_file
+}
+
+// An argument of an instruction, with the position of its value in the code.
+type argument struct {
+	Type scm.DataType
+
+	// Offset of the value (after the type byte) from the start of the code.
+	Offset int
+	Length int
+}
+
+// Reads the value of an integer or variable argument.
+func (arg argument) integer(code []byte) int32 {
+	value := code[arg.Offset : arg.Offset+arg.Length]
+
+	switch arg.Length {
+	case 1:
+		return int32(int8(value[0]))
+	case 2:
+		return int32(int16(binary.LittleEndian.Uint16(value)))
+	case 4:
+		return int32(binary.LittleEndian.Uint32(value))
+	}
+
+	return 0
+}
+
+// The array part of an array element argument.
+type arrayAccess struct {
+	// Global byte offset or local index of the first element.
+	FirstVariable uint16
+
+	// Global byte offset or local index of the variable used as the index.
+	IndexVariable uint16
+
+	Size  uint8
+	Flags uint8
+}
+
+func (arg argument) array(code []byte) arrayAccess {
+	access := arrayAccess{}
+	binary.Read(bytes.NewReader(code[arg.Offset:arg.Offset+arg.Length]), binary.LittleEndian, &access)
+
+	return access
+}
+
+// Whether the variable used as the index of an array is global.
+func (access arrayAccess) hasGlobalIndex() bool {
+	return access.Flags&0x80 != 0
+}
+
+// Reads the instruction at `offset`, returning its opcode, arguments and length.
+func readInstructionAt(code []byte, offset int) (opcode int, arguments []argument, length int, err error) {
+	// The scm package panics on bad types, so catch that and return it as an error.
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("bad instruction at %d: %v", offset, recovered)
+		}
+	}()
+
+	if len(code)-offset < 2 {
+		return 0, nil, 0, fmt.Errorf("truncated instruction at %d", offset)
+	}
+
+	// The scm package only tells us how many arguments an instruction has, so we work out
+	//  where the arguments are ourselves.
+	instruction := scm.ReadInstruction(bytes.NewReader(code[offset:]))
+
+	if instruction == nil {
+		return 0, nil, 0, fmt.Errorf("unknown opcode at %d", offset)
+	}
+
+	position := offset + 2
+	isVariadic := variadicOpcodes[instruction.Opcode]
+
+	for i := 0; isVariadic || i < len(instruction.Arguments); i++ {
+		if position >= len(code) {
+			return 0, nil, 0, fmt.Errorf("truncated instruction at %d", offset)
+		}
+
+		dataType := scm.ConcreteType(code[position]).Lift()
+		position++
+
+		valueLength := dataType.Concrete.ValueLength()
+
+		if dataType.IsConcrete(scm.ConcreteVariableString) {
+			if position >= len(code) {
+				return 0, nil, 0, fmt.Errorf("truncated instruction at %d", offset)
+			}
+
+			// Skip the length byte.
+			valueLength = int(code[position])
+			position++
+		}
+
+		if position+valueLength > len(code) {
+			return 0, nil, 0, fmt.Errorf("truncated instruction at %d", offset)
+		}
+
+		if dataType.IsAbstract(scm.AbstractEnd) {
+			break
+		}
+
+		arguments = append(arguments, argument{Type: dataType, Offset: position, Length: valueLength})
+		position += valueLength
+	}
+
+	return instruction.Opcode, arguments, position - offset, nil
+}
+
+// Calls `visit` for each instruction in `code`, stopping at the first error.
+func walkInstructions(code []byte, visit func(offset int, opcode int, arguments []argument)) error {
+	for offset := 0; offset < len(code); {
+		opcode, arguments, length, err := readInstructionAt(code, offset)
+
+		if err != nil {
+			return err
+		}
+
+		visit(offset, opcode, arguments)
+		offset += length
+	}
+
+	return nil
+}
+
+// Returns the indices of every local variable used by `code`.
+func usedLocals(code []byte) (map[int]bool, error) {
+	used := map[int]bool{}
+
+	markRange := func(first int, count int) {
+		for i := 0; i < count; i++ {
+			used[first+i] = true
+		}
+	}
+
+	err := walkInstructions(code, func(offset int, opcode int, arguments []argument) {
+		for _, arg := range arguments {
+			switch arg.Type.Concrete {
+			case scm.ConcreteLocal32:
+				markRange(int(arg.integer(code)), 1)
+
+			// Strings take up more than one variable.
+			case scm.ConcreteLocalString8:
+				markRange(int(arg.integer(code)), 2)
+
+			case scm.ConcreteLocalString16:
+				markRange(int(arg.integer(code)), 4)
+
+			case scm.ConcreteLocal32Element, scm.ConcreteLocalString8Element, scm.ConcreteLocalString16Element:
+				access := arg.array(code)
+
+				elementSize := 1
+
+				if arg.Type.IsConcrete(scm.ConcreteLocalString8Element) {
+					elementSize = 2
+				} else if arg.Type.IsConcrete(scm.ConcreteLocalString16Element) {
+					elementSize = 4
+				}
+
+				markRange(int(access.FirstVariable), int(access.Size)*elementSize)
+
+				if !access.hasGlobalIndex() {
+					markRange(int(access.IndexVariable), 1)
+				}
+
+			case scm.ConcreteGlobal32Element, scm.ConcreteGlobalString8Element, scm.ConcreteGlobalString16Element:
+				// Global arrays can still use a local as the index.
+				if access := arg.array(code); !access.hasGlobalIndex() {
+					markRange(int(access.IndexVariable), 1)
+				}
+			}
+		}
+	})
+
+	return used, err
+}
+
+// Returns the end (in bytes) of the highest global variable used by `code`. Globals are
+// referred to by their byte offsets in global space.
+func usedGlobalSpace(code []byte) (uint32, error) {
+	var end uint32 = 0
+
+	markRange := func(firstOffset uint16, byteCount int) {
+		if rangeEnd := uint32(firstOffset) + uint32(byteCount); rangeEnd > end {
+			end = rangeEnd
+		}
+	}
+
+	err := walkInstructions(code, func(offset int, opcode int, arguments []argument) {
+		for _, arg := range arguments {
+			switch arg.Type.Concrete {
+			case scm.ConcreteGlobal32:
+				markRange(uint16(arg.integer(code)), 4)
+
+			case scm.ConcreteGlobalString8:
+				markRange(uint16(arg.integer(code)), 8)
+
+			case scm.ConcreteGlobalString16:
+				markRange(uint16(arg.integer(code)), 16)
+
+			case scm.ConcreteGlobal32Element, scm.ConcreteGlobalString8Element, scm.ConcreteGlobalString16Element:
+				access := arg.array(code)
+
+				elementSize := 4
+
+				if arg.Type.IsConcrete(scm.ConcreteGlobalString8Element) {
+					elementSize = 8
+				} else if arg.Type.IsConcrete(scm.ConcreteGlobalString16Element) {
+					elementSize = 16
+				}
+
+				markRange(access.FirstVariable, int(access.Size)*elementSize)
+
+				if access.hasGlobalIndex() {
+					markRange(access.IndexVariable, 4)
+				}
+
+			case scm.ConcreteLocal32Element, scm.ConcreteLocalString8Element, scm.ConcreteLocalString16Element:
+				// Local arrays can still use a global as the index.
+				if access := arg.array(code); access.hasGlobalIndex() {
+					markRange(access.IndexVariable, 4)
+				}
+			}
+		}
+	})
+
+	return end, err
+}
@@ -3,189 +3,608 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
+	"flag"
 	"fmt"
 	"gta_save/save"
-	"io"
+	"math"
 	"os"
 	"path"
+	"sort"
 
 	"github.com/Squ1dd13/scm"
 )
 
-func translateOffsets(codeBytes []byte, byteOffset uint32) {
-	reader := bytes.NewReader(codeBytes)
+// Values that scripts can use in integer arguments to stand for things that are only known
+// once the script has been placed in global space. They're unlikely to be used for anything
+// else, since they're huge and don't mean anything as floats.
+const (
+	// The byte offset of the start of the script in script space.
+	embedBasePlaceholder = 0x7fbe0001
 
-	getByteIndex := func() int64 {
-		value, _ := reader.Seek(0, io.SeekCurrent)
-		return value
+	// The index of the global that the script starts at.
+	embedGlobalIndexPlaceholder = 0x7fbe0002
+
+	// The length of the script in bytes.
+	embedLengthPlaceholder = 0x7fbe0003
+
+	// The byte offset, global index and length of each data blob. The blob's number
+	//  (counting from 0, in the order they were given) is added to the placeholder.
+	embedDataBasePlaceholder        = 0x7fbe1000
+	embedDataGlobalIndexPlaceholder = 0x7fbe2000
+	embedDataLengthPlaceholder      = 0x7fbe3000
+)
+
+// A blob of data embedded in global space after a script.
+type dataBlob struct {
+	name     string
+	contents []byte
+
+	// Byte offset of the blob in global space, once it has been placed.
+	position uint32
+}
+
+// Patches the script's jumps to match where it is in global space, and replaces any
+// placeholders with their values.
+func translateOffsets(codeBytes []byte, byteOffset uint32, blobs []dataBlob) {
+	const (
+		jumpOpcode           = 0x0002
+		falseJumpOpcode      = 0x004d
+		callOpcode           = 0x0050
+		switchOpcode         = 0x0871
+		switchContinueOpcode = 0x0872
+	)
+
+	placeholders := map[int32]uint32{
+		embedBasePlaceholder:        byteOffset,
+		embedGlobalIndexPlaceholder: byteOffset / 4,
+		embedLengthPlaceholder:      uint32(len(codeBytes)),
+	}
+
+	for i, blob := range blobs {
+		placeholders[embedDataBasePlaceholder+int32(i)] = blob.position
+		placeholders[embedDataGlobalIndexPlaceholder+int32(i)] = blob.position / 4
+		placeholders[embedDataLengthPlaceholder+int32(i)] = uint32(len(blob.contents))
 	}
 
+	substitutionCount := 0
+
 	// Disassemble each instruction so we can check if we need to patch them.
-	for reader.Len() != 0 {
-		instructionIndex := getByteIndex()
-		instruction := scm.ReadInstruction(reader)
+	err := walkInstructions(codeBytes, func(offset int, opcode int, arguments []argument) {
+		for i, arg := range arguments {
+			if !arg.Type.IsConcrete(scm.ConcreteSigned32) {
+				continue
+			}
+
+			value := arg.integer(codeBytes)
+			addressBytes := codeBytes[arg.Offset : arg.Offset+4]
 
-		if instruction == nil {
-			println("Bad instruction, stopping.")
-			break
+			// TODO: Patch switches.
+
+			switch {
+			case i == 0 && (opcode == jumpOpcode || opcode == falseJumpOpcode || opcode == callOpcode):
+				binary.LittleEndian.PutUint32(addressBytes, uint32(-value+int32(byteOffset)))
+				println("Patched")
+
+			default:
+				if replacement, found := placeholders[value]; found {
+					binary.LittleEndian.PutUint32(addressBytes, replacement)
+					substitutionCount++
+				}
+			}
 		}
+	})
 
-		const (
-			jumpOpcode           = 0x0002
-			falseJumpOpcode      = 0x004d
-			callOpcode           = 0x0050
-			switchOpcode         = 0x0871
-			switchContinueOpcode = 0x0872
-		)
+	if err != nil {
+		fmt.Printf("Warning: Stopped patching the script: %v\n", err)
+	}
 
-		// TODO: Patch switches.
+	if substitutionCount != 0 {
+		fmt.Printf("Replaced %d placeholders.\n", substitutionCount)
+	}
+}
 
-		switch instruction.Opcode {
-		case jumpOpcode, falseJumpOpcode, callOpcode:
-			{
-				// Offset by 3 to skip past the opcode and type byte, then end at +7 after 4 bytes.
-				addressSlice := codeBytes[instructionIndex+3 : instructionIndex+7]
+// Works out how many locals the script uses, given the number of locals it can use. TIMERA
+// and TIMERB are accessed as the two locals after the last real local, so they don't count.
+func countLocals(localLimit int, scriptBytes []byte) (int, error) {
+	used, err := usedLocals(scriptBytes)
 
-				addressBuffer := bytes.NewBuffer(addressSlice)
+	if err != nil {
+		return 0, err
+	}
 
-				var address int32
-				err := binary.Read(addressBuffer, binary.LittleEndian, &address)
+	count := 0
 
-				if err != nil {
-					fmt.Printf("Failed to read address for jump/call at %d: %v\n", instructionIndex, err)
-					continue
-				}
+	for index := range used {
+		if index == localLimit || index == localLimit+1 {
+			continue
+		}
 
-				address = -address + int32(byteOffset)
+		if index+1 > count {
+			count = index + 1
+		}
+	}
 
-				addressBuffer.Reset()
-				binary.Write(addressBuffer, binary.LittleEndian, &address)
+	return count, nil
+}
 
-				println("Patched")
+// Works out where in global space to embed `length` bytes of code, so that they don't
+// overlap globals that main.scm uses or anything that has already been embedded. If a
+// position was chosen, it's checked instead.
+func embeddingPosition(saveFile *save.File, settings *embedSettings, length uint32) (uint32, error) {
+	mainFile := settings.mainFile
+	usedSpace := uint32(0)
+
+	if mainFile != nil {
+		var err error
+
+		if usedSpace, err = mainFile.usedGlobalSpace(); err != nil {
+			fmt.Printf("Warning: Only part of main.scm could be read, so it may use more globals than were found: %v\n", err)
+		}
+	}
+
+	if settings.position != nil {
+		position := *settings.position
+
+		if position%4 != 0 {
+			return 0, fmt.Errorf("offset %d is not aligned to a variable (a multiple of 4)", position)
+		}
+
+		if position < usedSpace {
+			return 0, fmt.Errorf("offset %d is among the globals that main.scm uses (up to byte %d)", position, usedSpace)
+		}
+
+		if end := uint64(position) + uint64(length); end > save.GlobalSpaceLimit {
+			return 0, fmt.Errorf("the script would end at byte %d, but global space can only be %d bytes", end, save.GlobalSpaceLimit)
+		}
+
+		// Running scripts are checked when the script is added, but there could be
+		//  data or code that nothing is running yet.
+		globals := saveFile.Scripts.GlobalVariables()
+		nonZeroCount := 0
+
+		for index := position / 4; index < (position+length+3)/4 && int(index) < len(globals); index++ {
+			if globals[index] != 0 {
+				nonZeroCount++
 			}
 		}
+
+		if nonZeroCount != 0 {
+			return 0, fmt.Errorf("the script would overwrite globals at offset %d that aren't zero (%d of them)", position, nonZeroCount)
+		}
+
+		return position, nil
 	}
+
+	// Anything already embedded is at the end of global space, so the end is always free.
+	position := saveFile.Scripts.GlobalByteCount()
+
+	if mainFile == nil {
+		return position, nil
+	}
+
+	if position > mainFile.globalSpaceSize {
+		fmt.Printf("The save already has %d bytes embedded in global space. The script will go after them.\n",
+			position-mainFile.globalSpaceSize)
+	}
+
+	if usedSpace > position {
+		fmt.Printf("main.scm uses global space up to byte %d, but the save only has %d bytes. The script will go after the globals main.scm uses.\n",
+			usedSpace, position)
+
+		position = usedSpace
+	}
+
+	// Code has to start at the beginning of a variable.
+	return (position + 3) &^ 3, nil
+}
+
+// How doEmbedding should embed a script, apart from the options for the script itself.
+type embedSettings struct {
+	fixValues       bool
+	inMissionBuffer bool
+
+	// The game's main.scm, or nil if it wasn't given.
+	mainFile *mainScm
+
+	// Where to put the script in global space, or nil to pick a position automatically.
+	position *uint32
+
+	// Data to put in global space after the script.
+	blobs []dataBlob
 }
 
 // This should be split up into a bunch of more flexible functions (or methods?) in the future.
 // Currently this is just experimental.
-func doEmbedding(input *os.File, scriptBytes []byte, output *os.File) {
-	platform := save.NewGamePlatform(input)
-	fmt.Printf("Detected platform: %s\n", platform.ToString())
+func doEmbedding(saveFile *save.File, scriptBytes []byte, options *save.ScriptOptions, settings *embedSettings) {
+	platform := &saveFile.Platform
+	scripts := &saveFile.Scripts
 
-	block0 := save.ReadVarBlock(&platform, input)
-	scripts := save.ReadScriptBlock(&platform, input)
+	localLimit := platform.MaxLocals()
 
-	const expandedByteCount = 60000
+	if settings.inMissionBuffer {
+		localLimit = save.MissionLocalCount
+	}
 
-	// Calculate the space we're adding so we know how much we have to play with.
-	oldSpace := scripts.GlobalByteCount()
-	addedSpace := expandedByteCount - oldSpace
+	localCount, err := countLocals(localLimit, scriptBytes)
 
-	fmt.Printf("Adding %d bytes to global store.\n", addedSpace)
-	scripts.ExpandGlobalSpace(int(expandedByteCount) / 4)
+	if err != nil {
+		fmt.Printf("Warning: Unable to work out how many locals the script uses: %v\n", err)
+	}
 
-	// Translate jumps to match the embedded location.
-	translateOffsets(scriptBytes, oldSpace)
+	options.LocalCount = localCount
 
-	scripts.AddScript(&platform, &block0, "embed", scriptBytes, oldSpace)
+	// Check the options before anything is printed or changed.
+	if err := options.Validate(scriptBytes, localLimit, &saveFile.Vars); err != nil {
+		fmt.Printf("Unable to add script: %v\n", err)
+		os.Exit(1)
+	}
 
-	targetLength := 195000
+	if settings.inMissionBuffer {
+		// The whole buffer and the mission locals are stored with the script.
+		fmt.Printf("Adding %d bytes of mission buffer and locals to the script block.\n", save.MissionBufferSize)
 
-	if platform.IsPC {
-		targetLength = 202752
-	}
+		// Mission code uses jumps relative to the start of the mission buffer, so it can go
+		//  in without any changes.
+		err = scripts.AddMissionScript(platform, &saveFile.Vars, "embed", scriptBytes, options)
+	} else {
+		const expandedByteCount = 60000
 
-	// TODO: Target length for PS2 platform.
+		// The data goes after the script, with everything starting on a new variable.
+		wideLength := (uint64(len(scriptBytes)) + 3) &^ 3
 
-	buffer := bytes.NewBuffer(make([]byte, 0, targetLength))
+		for _, blob := range settings.blobs {
+			wideLength += (uint64(len(blob.contents)) + 3) &^ 3
+		}
 
-	save.WriteVarBlock(&platform, buffer, &block0)
-	save.WriteScriptBlock(&platform, buffer, &scripts)
+		if wideLength > save.GlobalSpaceLimit {
+			fmt.Printf("Unable to add script: it and its data take up %d bytes, but global space can only be %d bytes.\n",
+				wideLength, save.GlobalSpaceLimit)
+			os.Exit(1)
+		}
 
-	// Copy the remaining data from the input file to the output buffer.
-	readBuffer := make([]byte, 512)
+		totalLength := uint32(wideLength)
+		position, positionErr := embeddingPosition(saveFile, settings, totalLength)
 
-	for {
-		read, err := input.Read(readBuffer)
+		if positionErr != nil {
+			fmt.Printf("Unable to add script: %v\n", positionErr)
+			os.Exit(1)
+		}
 
-		if read == 0 {
-			break
+		// Checked in 64 bits, since the position can be anywhere when it's given with -at.
+		if wideEnd := uint64(position) + wideLength; wideEnd > save.GlobalSpaceLimit {
+			fmt.Printf("Unable to add script: it would end at byte %d, but global space can only be %d bytes.\n",
+				wideEnd, save.GlobalSpaceLimit)
+			os.Exit(1)
 		}
 
-		if err != nil && err != io.EOF {
-			panic(err)
+		// Expand global space so that the script fits, but to at least the usual size so
+		//  there's room to spare (unless that would reach main.scm's code). Global space
+		//  never shrinks.
+		newSpace := uint32(expandedByteCount)
+
+		if settings.mainFile != nil && newSpace > settings.mainFile.codeOffset {
+			newSpace = settings.mainFile.codeOffset
+		}
+
+		end := position + totalLength
+
+		if end > newSpace {
+			newSpace = end
+		}
+
+		if oldSpace := scripts.GlobalByteCount(); oldSpace > newSpace {
+			newSpace = oldSpace
+		}
+
+		// Global space is loaded over the start of main.scm, so it can't reach the code.
+		if settings.mainFile != nil && end > settings.mainFile.codeOffset {
+			fmt.Printf("Unable to add script: it would end at byte %d, past the start of main.scm's code (at byte %d).\n",
+				end, settings.mainFile.codeOffset)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Adding %d bytes to global store.\n", newSpace-scripts.GlobalByteCount())
+		scripts.ExpandGlobalSpace(int(newSpace) / 4)
+
+		blobPosition := position + (uint32(len(scriptBytes))+3)&^3
+
+		for i := range settings.blobs {
+			blob := &settings.blobs[i]
+			blob.position = blobPosition
+			blobPosition += (uint32(len(blob.contents)) + 3) &^ 3
+
+			fmt.Printf("Data %d '%s': %d bytes at byte %d ($%d).\n", i, blob.name, len(blob.contents), blob.position, blob.position/4)
 		}
 
-		_, err = buffer.Write(readBuffer[:read])
+		// Translate jumps to match the embedded location.
+		translateOffsets(scriptBytes, position, settings.blobs)
+
+		err = scripts.AddScript(platform, &saveFile.Vars, "embed", scriptBytes, position, options)
 
-		if err != nil {
-			panic(err)
+		for i := 0; err == nil && i < len(settings.blobs); i++ {
+			err = scripts.EmbedData(settings.blobs[i].contents, settings.blobs[i].position)
 		}
 	}
 
-	length := buffer.Len()
+	if err != nil {
+		fmt.Printf("Unable to add script: %v\n", err)
+		os.Exit(1)
+	}
 
-	// TODO: Review this
-	if platform.IsPC || (platform.IsMobile && length > targetLength) {
-		println("Warning: Removing bytes from end of save to restrict length. " +
-			"This will likely cause issues if these bytes are not padding.")
+	if settings.fixValues {
+		scripts.RecomputeValues()
+	}
 
-		length = targetLength
+	for _, problem := range scripts.CheckValues() {
+		fmt.Printf("Warning: %s.\n", problem)
 	}
+}
 
-	finalBytes := buffer.Bytes()[:length-4]
+// Reads and parses the save at `path` without printing anything but warnings, exiting if it
+// can't be opened.
+func readSave(path string) save.File {
+	inputFile, err := os.OpenFile(path, os.O_RDONLY, 0755)
 
-	var checksum uint32 = 0
-	for _, value := range finalBytes {
-		checksum += uint32(value)
+	if err != nil {
+		println("Error opening input file. Please check the path and try again.")
+		os.Exit(1)
 	}
 
-	checksumBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(checksumBytes, checksum)
+	defer inputFile.Close()
+
+	saveFile := save.ReadFile(inputFile)
+	warnAboutLinks(path, &saveFile)
 
-	finalBytes = append(finalBytes, checksumBytes...)
+	return saveFile
+}
 
-	_, err := output.Write(finalBytes)
+// Like readSave, but returns an error instead of exiting if the save can't be read.
+func readSaveChecked(path string) (save.File, error) {
+	inputFile, err := os.Open(path)
 
 	if err != nil {
-		panic(err)
+		return save.File{}, err
+	}
+
+	defer inputFile.Close()
+
+	saveFile, err := save.ReadFileChecked(inputFile)
+
+	if err == nil {
+		warnAboutLinks(path, &saveFile)
 	}
+
+	return saveFile, err
 }
 
-func main() {
-	arguments := os.Args[1:]
+func warnAboutLinks(path string, saveFile *save.File) {
+	if err := saveFile.Scripts.ValidateLinks(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: The running script list in '%s' is corrupt: %v\n", path, err)
+		fmt.Fprintln(os.Stderr, "Scripts can't be added or removed unless -relink is given to rebuild the list.")
+	}
+}
+
+// Adds the -relink option to a command that adds or removes running scripts.
+func addRelinkFlag(flags *flag.FlagSet) *bool {
+	return flags.Bool("relink", false, "rebuild the running script list even if its links are corrupt (the result may not load)")
+}
+
+// Reads and parses the save at `path`, exiting if it can't be opened.
+func loadSave(path string) save.File {
+	saveFile := readSave(path)
+	fmt.Printf("Detected platform: %s\n", saveFile.Platform.ToString())
+
+	return saveFile
+}
+
+// Writes `saveFile` to `path`, exiting if it can't be written.
+func writeSave(path string, saveFile *save.File) {
+	// Build the save in memory first, so that nothing is written if it can't be built.
+	encoded := bytes.Buffer{}
+
+	if err := save.WriteFile(&encoded, saveFile); err != nil {
+		fmt.Printf("Unable to write save: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(path, encoded.Bytes(), 0755); err != nil {
+		println("Error opening output file. Please check the path and that the destination is writeable.")
+		os.Exit(1)
+	}
+}
+
+func embedCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	locals := variableValues{}
+	flags.Var(locals, "local", "initial value for a local variable, as `<index>=<value>` (repeatable)")
+
+	timerA := flags.Int("timera", 0, "initial value for TIMERA")
+	timerB := flags.Int("timerb", 0, "initial value for TIMERB")
+
+	isMission := flags.Bool("mission", false, "mark the script as a mission script")
+	usesCleanup := flags.Bool("cleanup", false, "register the script with the mission cleanup")
+	isExternal := flags.Bool("external", false, "mark the script as external (streamed)")
+	gameOverCheck := flags.Bool("gameover-check", false, "enable the wasted/busted check for the script")
+	wantedOrBusted := flags.Bool("wasted-busted", false, "set the script's wasted or busted flag")
+
+	delay := flags.Uint("delay", 0, "milliseconds to wait after loading before the script starts")
+	entry := flags.Int64("entry", 0, "byte offset of the label to start at (negative offsets from Sanny Builder are accepted)")
+
+	fixValues := flags.Bool("fix-values", false, "recompute the script block's summary values where possible")
+	inMissionBuffer := flags.Bool("mission-buffer", false, "embed the script in the mission buffer instead of global space")
+	mainPath := flags.String("main", "", "the game's `main.scm`, used to keep the script clear of the globals it uses")
+	relink := addRelinkFlag(flags)
+	emitPatch := flags.Bool("emit-patch", false, "write a patch that apply-patch can use on other saves, instead of a modded save")
+
+	fields := fieldAssignments{}
+	flags.Var(&fields, "set", "set a field once the script is embedded, as `<path>=<value>` like the set command takes (repeatable)")
+
+	var position *uint32
+
+	blobs := dataBlobs{}
+	flags.Var(&blobs, "data", "embed the file at `path` after the script as data blob n, where n counts the -data flags from 0 (repeatable, see the README for how to find it)")
+
+	flags.Func("at", "put the script at this byte `offset` in global space, or at a global given as $<index>", func(str string) error {
+		offset, err := parseGlobalPosition(str)
+		position = &offset
+
+		return err
+	})
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <path to save file> <path to script> <destination for modded save file (or patch)>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+	arguments = flags.Args()
 
 	if len(arguments) != 3 {
-		fileName := path.Base(os.Args[0])
-		fmt.Printf("Usage: '%s <path to save file> <path to script> <destination for modded save file>'\n", fileName)
+		flags.Usage()
 		os.Exit(1)
 	}
 
-	inputFile, err := os.OpenFile(arguments[0], os.O_RDONLY, 0755)
+	if position != nil && *inMissionBuffer {
+		println("-at can't be used with -mission-buffer, because mission scripts always start at the beginning of the buffer.")
+		os.Exit(1)
+	}
 
-	if err != nil {
-		println("Error opening input file. Please check the path and try again.")
+	if len(blobs) != 0 && *inMissionBuffer {
+		println("-data can't be used with -mission-buffer, because data is embedded in global space.")
 		os.Exit(1)
 	}
 
-	defer inputFile.Close()
+	if *delay > math.MaxUint32 {
+		fmt.Printf("-delay must be at most %d milliseconds.\n", uint32(math.MaxUint32))
+		os.Exit(1)
+	}
+
+	for _, timer := range []*int{timerA, timerB} {
+		if *timer < math.MinInt32 || *timer > math.MaxInt32 {
+			fmt.Printf("Timer value %d doesn't fit in a script variable.\n", *timer)
+			os.Exit(1)
+		}
+	}
+
+	if *emitPatch && *inMissionBuffer {
+		println("-emit-patch can't be used with -mission-buffer, because patches only add to global space.")
+		os.Exit(1)
+	}
+
+	options := save.ScriptOptions{
+		Locals: locals,
+		Timers: [2]uint32{save.IntValue(int32(*timerA)), save.IntValue(int32(*timerB))},
+
+		IsMission:           *isMission,
+		UsesMissionCleanup:  *usesCleanup,
+		IsExternal:          *isExternal,
+		GameOverCheckActive: *gameOverCheck,
+		WantedOrBusted:      *wantedOrBusted,
+
+		ActivationDelay: uint32(*delay),
+	}
+
+	// Custom script labels are compiled as negative offsets, so accept those as well.
+	if *entry < -math.MaxUint32 || *entry > math.MaxUint32 {
+		fmt.Printf("-entry %d is too far from the start of the script.\n", *entry)
+		os.Exit(1)
+	}
+
+	if *entry < 0 {
+		options.EntryOffset = uint32(-*entry)
+	} else {
+		options.EntryOffset = uint32(*entry)
+	}
 
+	saveFile := loadSave(arguments[0])
 	scriptBytes, err := os.ReadFile(arguments[1])
 
+	if *relink {
+		saveFile.Scripts.AllowRelinkingCorruptList()
+	}
+
 	if err != nil {
 		println("Error opening script file. Please check the path and try again.")
 		os.Exit(1)
 	}
 
-	outputFile, err := os.OpenFile(arguments[2], os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
+	settings := embedSettings{fixValues: *fixValues, inMissionBuffer: *inMissionBuffer, position: position, blobs: blobs}
 
-	if err != nil {
-		println("Error opening output file. Please check the path and that the destination is writeable.")
+	if *mainPath != "" {
+		settings.mainFile = readMainScmForSave(*mainPath, &saveFile)
+	}
+
+	if *emitPatch {
+		// Embed into this save anyway, so that any problems show up now.
+		patch := makePatch(&saveFile, scriptBytes, &options, &settings, fields)
+		doEmbedding(&saveFile, scriptBytes, &options, &settings)
+		setFields(&saveFile, fields)
+		writePatch(arguments[2], &patch)
+
+		return
+	}
+
+	doEmbedding(&saveFile, scriptBytes, &options, &settings)
+	setFields(&saveFile, fields)
+	writeSave(arguments[2], &saveFile)
+}
+
+type command struct {
+	usage string
+	run   func(name string, arguments []string)
+}
+
+var commands = map[string]command{
+	"embed":       {"embed a script in a save", embedCommand},
+	"brains":      {"list, add, change or remove script brains", brainsCommand},
+	"arrays":      {"list, add or remove model swaps, hidden objects and other script arrays", arraysCommand},
+	"inspect":     {"show the state of every running script", inspectCommand},
+	"scripts":     {"change, pause, delay or remove running scripts", scriptsCommand},
+	"globals":     {"read or change global variables by name or index", globalsCommand},
+	"edit":        {"change the clock, weather, wanted level and other world state", editCommand},
+	"flags":       {"show or change the cheated and censorship flags", flagsCommand},
+	"info":        {"summarise a save", infoCommand},
+	"dump":        {"write a whole save as JSON", dumpCommand},
+	"load":        {"build a save from JSON written by dump", loadCommand},
+	"get":         {"print fields of a save by path", getCommand},
+	"set":         {"change fields of a save by path", setCommand},
+	"diff":        {"list the fields that differ between two saves", diffCommand},
+	"apply-patch": {"apply a patch made with embed -emit-patch to a save", applyPatchCommand},
+}
+
+func printUsage(fileName string) {
+	fmt.Printf("Usage: '%s [options] <path to save file> <path to script> <destination for modded save file>'\n", fileName)
+	fmt.Printf("   or: '%s <command> [arguments]'\n\nCommands:\n", fileName)
+
+	names := make([]string, 0, len(commands))
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	fileName := path.Base(os.Args[0])
+
+	if len(os.Args) < 2 || os.Args[1] == "help" {
+		printUsage(fileName)
 		os.Exit(1)
 	}
 
-	defer outputFile.Close()
+	if len(os.Args) > 1 {
+		if theCommand, found := commands[os.Args[1]]; found {
+			theCommand.run(fileName+" "+os.Args[1], os.Args[2:])
+			return
+		}
+	}
 
-	doEmbedding(inputFile, scriptBytes, outputFile)
+	// Without a command, we embed as we always have.
+	embedCommand(fileName, os.Args[1:])
 }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gta_save/save"
+	"os"
+
+	"github.com/Squ1dd13/scm"
+)
+
+// main.scm starts with a number of segments, each of which begins with a jump over the
+// segment's contents and a byte identifying the segment. Global space is the first segment,
+// so globals are addressed from the start of the file, and the main script's code starts
+// after the last segment. The missions follow the main script's code.
+const mainScmSegmentCount = 6
+
+// The size of the jump and identifier byte at the start of each segment.
+const segmentHeaderSize = 8
+
+// A script in main.scm's streamed script table. The code itself is in script.img.
+type streamedScript struct {
+	name   string
+	offset uint32
+	size   uint32
+}
+
+// The game's main.scm, with its segments parsed.
+type mainScm struct {
+	contents []byte
+
+	// The amount of global space that main.scm declares, including the jump at the start.
+	globalSpaceSize uint32
+
+	// Offset of the first instruction after the segments.
+	codeOffset uint32
+
+	// Names of the models that the code refers to with negative IDs. The first is unused.
+	models []string
+
+	// The size of the main script, including the segments, and the mission table. Each
+	//  mission runs from its offset to the start of the next mission (or the end of the file).
+	mainSize              uint32
+	largestMissionSize    uint32
+	exclusiveMissionCount uint16
+	largestMissionLocals  uint32
+	missionOffsets        []uint32
+
+	largestStreamedSize uint32
+	streamedScripts     []streamedScript
+
+	// The contents of segments 5 and 6, which we don't know the meaning of.
+	unknownSegments [2][]byte
+}
+
+// Reads the jump at the start of the segment at `offset`, returning the offset of the next
+// segment.
+func (file *mainScm) segmentEnd(offset uint32) (uint32, error) {
+	if uint32(len(file.contents)) < offset+segmentHeaderSize {
+		return 0, fmt.Errorf("segment at %d is truncated", offset)
+	}
+
+	const jumpOpcode = 0x0002
+
+	opcode, arguments, _, err := readInstructionAt(file.contents, int(offset))
+
+	if err != nil || opcode != jumpOpcode || len(arguments) != 1 || !arguments[0].Type.IsConcrete(scm.ConcreteSigned32) {
+		return 0, fmt.Errorf("segment at %d doesn't start with a jump", offset)
+	}
+
+	target := uint32(arguments[0].integer(file.contents))
+
+	if target < offset+segmentHeaderSize || target > uint32(len(file.contents)) {
+		return 0, fmt.Errorf("segment at %d jumps to %d, which is outside the file", offset, target)
+	}
+
+	return target, nil
+}
+
+// Reads fixed-size values from the start of a segment's contents.
+func readSegmentValues(contents *bytes.Reader, values ...interface{}) error {
+	for _, value := range values {
+		if err := binary.Read(contents, binary.LittleEndian, value); err != nil {
+			return fmt.Errorf("segment is truncated")
+		}
+	}
+
+	return nil
+}
+
+// Checks that the rest of the segment is big enough for a table of `count` entries, each
+// `entrySize` bytes long.
+func checkSegmentTable(contents *bytes.Reader, count int, entrySize int) error {
+	if count*entrySize > contents.Len() {
+		return fmt.Errorf("table of %d entries doesn't fit in the segment", count)
+	}
+
+	return nil
+}
+
+func (file *mainScm) readModels(contents *bytes.Reader) error {
+	var count uint32
+
+	if err := readSegmentValues(contents, &count); err != nil {
+		return err
+	}
+
+	if err := checkSegmentTable(contents, int(count), 24); err != nil {
+		return err
+	}
+
+	names := make([][24]byte, count)
+	readSegmentValues(contents, names)
+
+	for _, name := range names {
+		file.models = append(file.models, terminatedString(name[:]))
+	}
+
+	return nil
+}
+
+func (file *mainScm) readMissions(contents *bytes.Reader) error {
+	var missionCount uint16
+
+	err := readSegmentValues(contents, &file.mainSize, &file.largestMissionSize, &missionCount,
+		&file.exclusiveMissionCount, &file.largestMissionLocals)
+
+	if err != nil {
+		return err
+	}
+
+	if err := checkSegmentTable(contents, int(missionCount), 4); err != nil {
+		return err
+	}
+
+	file.missionOffsets = make([]uint32, missionCount)
+	readSegmentValues(contents, file.missionOffsets)
+
+	for i, offset := range file.missionOffsets {
+		if offset < file.mainSize || offset > uint32(len(file.contents)) {
+			return fmt.Errorf("mission %d starts at %d, which is outside the mission code", i, offset)
+		}
+	}
+
+	return nil
+}
+
+func (file *mainScm) readStreamedScripts(contents *bytes.Reader) error {
+	var count uint32
+
+	if err := readSegmentValues(contents, &file.largestStreamedSize, &count); err != nil {
+		return err
+	}
+
+	if err := checkSegmentTable(contents, int(count), 28); err != nil {
+		return err
+	}
+
+	entries := make([]struct {
+		Name   [20]byte
+		Offset uint32
+		Size   uint32
+	}, count)
+
+	readSegmentValues(contents, entries)
+
+	for _, entry := range entries {
+		file.streamedScripts = append(file.streamedScripts, streamedScript{terminatedString(entry.Name[:]), entry.Offset, entry.Size})
+	}
+
+	return nil
+}
+
+// Returns the string before the first null in `data`.
+func terminatedString(data []byte) string {
+	if nullIndex := bytes.IndexByte(data, 0); nullIndex != -1 {
+		data = data[:nullIndex]
+	}
+
+	return string(data)
+}
+
+func readMainScm(path string) (*mainScm, error) {
+	contents, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	file := &mainScm{contents: contents}
+
+	if err := file.readSegments(); err != nil {
+		return nil, fmt.Errorf("%s is not a San Andreas main.scm: %v", path, err)
+	}
+
+	return file, nil
+}
+
+// Reads the main.scm that a script is being embedded alongside, and warns about anything in
+// the save that doesn't match it. This is done before the save is changed. Exits if main.scm
+// can't be read.
+func readMainScmForSave(path string, saveFile *save.File) *mainScm {
+	mainFile, err := readMainScm(path)
+
+	if err != nil {
+		fmt.Printf("Unable to read main.scm: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, problem := range mainFile.checkSave(saveFile) {
+		fmt.Printf("Warning: The save doesn't match main.scm: %s.\n", problem)
+	}
+
+	return mainFile
+}
+
+func (file *mainScm) readSegments() error {
+	offset := uint32(0)
+
+	for i := 0; i < mainScmSegmentCount; i++ {
+		end, err := file.segmentEnd(offset)
+
+		if err != nil {
+			return err
+		}
+
+		contents := bytes.NewReader(file.contents[offset+segmentHeaderSize : end])
+
+		switch i {
+		case 0:
+			// San Andreas marks global space with 's'.
+			if file.contents[offset+7] != 's' {
+				return fmt.Errorf("the file is for a different game")
+			}
+
+			file.globalSpaceSize = end
+
+		case 1:
+			err = file.readModels(contents)
+
+		case 2:
+			err = file.readMissions(contents)
+
+		case 3:
+			err = file.readStreamedScripts(contents)
+
+		default:
+			file.unknownSegments[i-4] = file.contents[offset+segmentHeaderSize : end]
+		}
+
+		if err != nil {
+			return fmt.Errorf("segment %d: %v", i+1, err)
+		}
+
+		offset = end
+	}
+
+	file.codeOffset = offset
+
+	if file.mainSize < file.codeOffset || file.mainSize > uint32(len(file.contents)) {
+		return fmt.Errorf("the main script is %d bytes, which doesn't fit the file", file.mainSize)
+	}
+
+	return nil
+}
+
+// Returns the code of each part of main.scm: the main script followed by each mission.
+func (file *mainScm) codeSections() [][]byte {
+	sections := [][]byte{file.contents[file.codeOffset:file.mainSize]}
+
+	for i, offset := range file.missionOffsets {
+		end := uint32(len(file.contents))
+
+		if i+1 < len(file.missionOffsets) {
+			end = file.missionOffsets[i+1]
+		}
+
+		// Missions should be in order, but don't rely on it.
+		if end < offset {
+			end = offset
+		}
+
+		sections = append(sections, file.contents[offset:end])
+	}
+
+	return sections
+}
+
+// Compares main.scm with the values that the save has for it, returning a description of
+// each mismatch. Mismatches usually mean that the save was made with a different main.scm.
+func (file *mainScm) checkSave(saveFile *save.File) []string {
+	problems := []string{}
+	values := &saveFile.Scripts.Values
+
+	compare := func(description string, saveValue uint32, mainValue uint32) {
+		if saveValue != mainValue {
+			problems = append(problems, fmt.Sprintf("%s is %d in the save, but %d in main.scm", description, saveValue, mainValue))
+		}
+	}
+
+	compare("the size of main.scm", values.MainScmSize, file.mainSize)
+	compare("the largest mission size", values.LargestMissionSize, file.largestMissionSize)
+	compare("the mission count", values.MissionCount, uint32(len(file.missionOffsets)))
+
+	// Embedding can raise the highest local and add to global space, so those only need
+	//  to be at least what main.scm has.
+	if values.HighestLocal < file.largestMissionLocals {
+		problems = append(problems, fmt.Sprintf("the highest local is %d in the save, but main.scm's missions use %d",
+			values.HighestLocal, file.largestMissionLocals))
+	}
+
+	if globalSpace := saveFile.Scripts.GlobalByteCount(); globalSpace < file.globalSpaceSize {
+		problems = append(problems, fmt.Sprintf("the save has %d bytes of global space, but main.scm declares %d",
+			globalSpace, file.globalSpaceSize))
+	}
+
+	return problems
+}
+
+// Returns the amount of global space that main.scm uses. This is normally the space that it
+// declares, but modified scripts can use globals past the end of that. If some of the code
+// can't be read, the space used by the rest of the code is returned along with the first
+// error.
+func (file *mainScm) usedGlobalSpace() (uint32, error) {
+	used := file.globalSpaceSize
+	var firstErr error
+
+	for i, section := range file.codeSections() {
+		sectionUsed, err := usedGlobalSpace(section)
+
+		if sectionUsed > used {
+			used = sectionUsed
+		}
+
+		if err != nil && firstErr == nil {
+			if i == 0 {
+				firstErr = fmt.Errorf("main script: %v", err)
+			} else {
+				firstErr = fmt.Errorf("mission %d: %v", i-1, err)
+			}
+		}
+	}
+
+	return used, firstErr
+}
+
+func inspectMainScm(file *mainScm) {
+	fmt.Printf("Global space: %d bytes\n", file.globalSpaceSize)
+	fmt.Printf("Models: %d\n", len(file.models))
+	fmt.Printf("Main script: %d bytes (code starts at %d)\n", file.mainSize, file.codeOffset)
+	fmt.Printf("Missions: %d (%d exclusive), largest %d bytes, using up to %d locals\n",
+		len(file.missionOffsets), file.exclusiveMissionCount, file.largestMissionSize, file.largestMissionLocals)
+	fmt.Printf("Streamed scripts: %d, largest %d bytes\n", len(file.streamedScripts), file.largestStreamedSize)
+
+	for i, streamed := range file.streamedScripts {
+		fmt.Printf("  %d: '%s' (%d bytes at %d)\n", i, streamed.name, streamed.size, streamed.offset)
+	}
+
+	if used, err := file.usedGlobalSpace(); err != nil {
+		fmt.Printf("Globals used: up to byte %d (only part of the code could be read: %v)\n", used, err)
+	} else {
+		fmt.Printf("Globals used: up to byte %d\n", used)
+	}
+}
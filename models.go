@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Model names, loaded from the game's .ide files.
+type modelNames struct {
+	names map[int32]string
+	ids   map[string]int32
+}
+
+func newModelNames() *modelNames {
+	return &modelNames{names: map[int32]string{}, ids: map[string]int32{}}
+}
+
+// Loads every model definition from an .ide file. Definitions in every section start with
+// the model ID followed by the model name, so we don't need to care about the sections.
+func (models *modelNames) load(path string) error {
+	fileBytes, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	for _, dirtyLine := range strings.Split(string(fileBytes), "\n") {
+		if commentIndex := strings.IndexRune(dirtyLine, '#'); -1 < commentIndex {
+			dirtyLine = dirtyLine[:commentIndex]
+		}
+
+		fields := strings.Split(dirtyLine, ",")
+
+		if len(fields) < 2 {
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 32)
+
+		if err != nil {
+			continue
+		}
+
+		name := strings.TrimSpace(fields[1])
+
+		models.names[int32(id)] = name
+		models.ids[strings.ToLower(name)] = int32(id)
+	}
+
+	return nil
+}
+
+// Returns the model's name and ID, or just the ID if the name isn't known.
+func (models *modelNames) describe(id int32) string {
+	if name, found := models.names[id]; found {
+		return fmt.Sprintf("%s (%d)", name, id)
+	}
+
+	return fmt.Sprint(id)
+}
+
+// Parses a model given either as an ID or as a name.
+func (models *modelNames) parse(str string) (int32, error) {
+	if id, err := strconv.ParseInt(str, 10, 32); err == nil {
+		return int32(id), nil
+	}
+
+	if id, found := models.ids[strings.ToLower(str)]; found {
+		return id, nil
+	}
+
+	return 0, fmt.Errorf("unknown model '%s' (load the game's .ide files with -ide to use names)", str)
+}
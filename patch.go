@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+)
+
+// A range of bytes to add to global space.
+type patchRange struct {
+	Name     string
+	Contents []byte
+}
+
+// A description of an embedding that can be applied to any save for the same platform.
+// Nothing in it depends on the save it was made from: the ranges are placed and the code is
+// translated when the patch is applied, just as if the script had been embedded then.
+type embedPatch struct {
+	// The platform the patch was made for. Other platforms have different script layouts.
+	Platform save.GamePlatform
+
+	// The ranges to add to global space, one after another. The first is the script's code
+	// (as it was compiled), and a running script is added that starts in it. The rest are
+	// data.
+	GlobalRanges []patchRange
+
+	// Where to put the ranges in global space, or null to put them after everything else.
+	Position *uint32
+
+	// The options for the running script.
+	ScriptOptions save.ScriptOptions
+
+	// Whether to recompute the script block's summary values afterwards.
+	FixValues bool
+
+	// Fields to set once the script has been added, as `<path>=<value>`.
+	Fields []string
+}
+
+func writePatch(path string, patch *embedPatch) {
+	writeJSON(path, patch, "the patch")
+}
+
+func readPatch(path string) embedPatch {
+	encoded, err := os.ReadFile(path)
+
+	if err != nil {
+		fmt.Printf("Unable to read '%s': %v\n", path, err)
+		os.Exit(1)
+	}
+
+	patch := embedPatch{}
+
+	if err := json.Unmarshal(encoded, &patch); err != nil {
+		fmt.Printf("Unable to load the patch in '%s': %v\n", path, err)
+		os.Exit(1)
+	}
+
+	if len(patch.GlobalRanges) == 0 {
+		fmt.Printf("The patch in '%s' has no code to add.\n", path)
+		os.Exit(1)
+	}
+
+	return patch
+}
+
+// Builds the patch for an embedding. This has to be done before the embedding, because the
+// code is translated in place.
+func makePatch(saveFile *save.File, scriptBytes []byte, options *save.ScriptOptions, settings *embedSettings, fields []string) embedPatch {
+	patch := embedPatch{
+		Platform:      saveFile.Platform,
+		GlobalRanges:  []patchRange{{"script", append([]byte{}, scriptBytes...)}},
+		Position:      settings.position,
+		ScriptOptions: *options,
+		FixValues:     settings.fixValues,
+		Fields:        append([]string{}, fields...),
+	}
+
+	for _, blob := range settings.blobs {
+		patch.GlobalRanges = append(patch.GlobalRanges, patchRange{blob.name, blob.contents})
+	}
+
+	return patch
+}
+
+func applyPatchCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	mainPath := flags.String("main", "", "the game's `main.scm`, used to keep the script clear of the globals it uses")
+	relink := addRelinkFlag(flags)
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <patch> <input save> <output save>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 3 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	patch := readPatch(flags.Arg(0))
+	saveFile := loadSave(flags.Arg(1))
+
+	if *relink {
+		saveFile.Scripts.AllowRelinkingCorruptList()
+	}
+
+	if patch.Platform != saveFile.Platform {
+		fmt.Printf("The patch was made for %s saves, so it can't be applied to a %s save.\n",
+			patch.Platform.ToString(), saveFile.Platform.ToString())
+		os.Exit(1)
+	}
+
+	settings := embedSettings{fixValues: patch.FixValues, position: patch.Position}
+
+	for _, dataRange := range patch.GlobalRanges[1:] {
+		settings.blobs = append(settings.blobs, dataBlob{name: dataRange.Name, contents: dataRange.Contents})
+	}
+
+	if *mainPath != "" {
+		settings.mainFile = readMainScmForSave(*mainPath, &saveFile)
+	}
+
+	doEmbedding(&saveFile, patch.GlobalRanges[0].Contents, &patch.ScriptOptions, &settings)
+	setFields(&saveFile, patch.Fields)
+	writeSave(flags.Arg(2), &saveFile)
+}
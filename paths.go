@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"os"
+)
+
+// Splits `<path>=<value>` at the first '=' that isn't inside a selector like "[name=main]".
+func splitAssignment(str string) (string, string, error) {
+	depth := 0
+
+	for i, character := range str {
+		switch {
+		case character == '[':
+			depth++
+		case character == ']':
+			depth--
+		case character == '=' && depth == 0:
+			return str[:i], str[i+1:], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("expected '<path>=<value>', got '%s'", str)
+}
+
+func getCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	asJson := flags.Bool("json", false, "print the values as JSON")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <save> <path>...'\n", name)
+		fmt.Println("Paths look like 'vars.Weather.ForcedWeatherType' or 'scripts.RunningScripts[name=main].Locals[3]'.")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := readSave(flags.Arg(0))
+
+	for _, path := range flags.Args()[1:] {
+		if *asJson {
+			value, err := saveFile.Get(path)
+
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			encoded, err := json.MarshalIndent(value, "", "  ")
+
+			if err != nil {
+				fmt.Printf("Unable to convert '%s' to JSON: %v\n", path, err)
+				os.Exit(1)
+			}
+
+			fmt.Println(string(encoded))
+			continue
+		}
+
+		values, err := saveFile.GetValues(path)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		// A single value is printed on its own so that it's easy to use from other tools.
+		if len(values) == 1 && values[0].Path == path {
+			fmt.Println(values[0].Value)
+			continue
+		}
+
+		for _, value := range values {
+			fmt.Printf("%s = %s\n", value.Path, value.Value)
+		}
+	}
+}
+
+func setCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s <input save> <output save> <path>=<value>...'\n", name)
+		fmt.Println("Paths look like 'vars.Weather.ForcedWeatherType' or 'scripts.RunningScripts[name=main].Locals[3]'.")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() < 3 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(flags.Arg(0))
+	setFields(&saveFile, flags.Args()[2:])
+	writeSave(flags.Arg(1), &saveFile)
+}
+
+// Applies `<path>=<value>` assignments to a save, stopping at the first that fails.
+func setFields(saveFile *save.File, assignments []string) {
+	for _, assignment := range assignments {
+		path, value, err := splitAssignment(assignment)
+
+		if err == nil {
+			err = saveFile.Set(path, value)
+		}
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Set %s to %s.\n", path, value)
+	}
+}
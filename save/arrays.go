@@ -0,0 +1,304 @@
+package save
+
+import "fmt"
+
+// The script arrays hold changes that scripts make to the world, like swapping building
+// models or hiding objects. Each array has a fixed number of slots, and a slot is free when
+// its contents match what the game uses for an empty slot.
+
+// Types of entity that model swaps and hidden objects can apply to.
+type EntityType uint32
+
+const (
+	EntityNone     EntityType = 0
+	EntityBuilding EntityType = 1
+	EntityObject   EntityType = 4
+	EntityDummy    EntityType = 5
+)
+
+func (entityType EntityType) ToString() string {
+	switch entityType {
+	case EntityNone:
+		return "none"
+	case EntityBuilding:
+		return "building"
+	case EntityObject:
+		return "object"
+	case EntityDummy:
+		return "dummy"
+	}
+
+	return fmt.Sprintf("unknown (%d)", uint32(entityType))
+}
+
+// Empty suppressed model and animation group slots hold -1 as the model.
+const freeModelId = 0xffffffff
+
+// Empty LOD assignment slots hold -1 as both handles.
+const freeHandle = 0xffffffff
+
+func (replacement *ModelReplacement) IsFree() bool {
+	return EntityType(replacement.Type) == EntityNone
+}
+
+func (object *InvisibleObject) IsFree() bool {
+	return EntityType(object.Type) == EntityNone
+}
+
+func (assignment *LodAssignment) IsFree() bool {
+	return assignment.ObjectHandle == freeHandle
+}
+
+func (assignment *ScriptAssignment) IsFree() bool {
+	return assignment.ActorModelId == freeModelId
+}
+
+// Returns the name stored in the assignment.
+func (assignment *ScriptAssignment) Name() string {
+	return storedScriptName(assignment.ScriptName)
+}
+
+func (assignment *ScriptAssignment) SetName(name string, policy LengthPolicy) error {
+	encoded, err := encodeScriptName(name, policy)
+
+	if err != nil {
+		return err
+	}
+
+	assignment.ScriptName = encoded
+	return nil
+}
+
+func checkEntityType(entityType EntityType) error {
+	switch entityType {
+	case EntityBuilding, EntityObject, EntityDummy:
+		return nil
+	}
+
+	return fmt.Errorf("entity type %d is not a building, object or dummy", uint32(entityType))
+}
+
+// Returns the indices of the slots that are not free.
+func usedSlots(count int, isFree func(int) bool) []int {
+	slots := []int{}
+
+	for i := 0; i < count; i++ {
+		if !isFree(i) {
+			slots = append(slots, i)
+		}
+	}
+
+	return slots
+}
+
+// Returns the index of the first free slot.
+func firstFreeSlot(count int, isFree func(int) bool, arrayName string) (int, error) {
+	for i := 0; i < count; i++ {
+		if isFree(i) {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("all %d %s slots are in use", count, arrayName)
+}
+
+func checkSlot(slot int, count int, arrayName string) error {
+	if slot < 0 || slot >= count {
+		return fmt.Errorf("%s slot %d does not exist (there are %d slots)", arrayName, slot, count)
+	}
+
+	return nil
+}
+
+// Model swaps.
+
+func (block *scriptBlock) isModelSwapFree(slot int) bool {
+	return block.Arrays.StaticReplacements[slot].IsFree()
+}
+
+func (block *scriptBlock) ModelSwapSlots() []int {
+	return usedSlots(len(block.Arrays.StaticReplacements), block.isModelSwapFree)
+}
+
+// Swaps the model of the entity with handle `handle` from `oldModelId` to `newModelId`.
+func (block *scriptBlock) AddModelSwap(entityType EntityType, handle uint32, oldModelId int32, newModelId int32) (int, error) {
+	if err := checkEntityType(entityType); err != nil {
+		return -1, err
+	}
+
+	slot, err := firstFreeSlot(len(block.Arrays.StaticReplacements), block.isModelSwapFree, "model swap")
+
+	if err != nil {
+		return slot, err
+	}
+
+	block.Arrays.StaticReplacements[slot] = ModelReplacement{
+		Type:       uint32(entityType),
+		Handle:     handle,
+		NewModelId: newModelId,
+		OldModelId: oldModelId,
+	}
+
+	return slot, nil
+}
+
+func (block *scriptBlock) RemoveModelSwap(slot int) error {
+	if err := checkSlot(slot, len(block.Arrays.StaticReplacements), "model swap"); err != nil {
+		return err
+	}
+
+	block.Arrays.StaticReplacements[slot] = ModelReplacement{}
+	return nil
+}
+
+// Hidden objects.
+
+func (block *scriptBlock) isHiddenObjectFree(slot int) bool {
+	return block.Arrays.InvisibleObjects[slot].IsFree()
+}
+
+func (block *scriptBlock) HiddenObjectSlots() []int {
+	return usedSlots(len(block.Arrays.InvisibleObjects), block.isHiddenObjectFree)
+}
+
+// Makes the entity with handle `handle` invisible.
+func (block *scriptBlock) AddHiddenObject(entityType EntityType, handle uint32) (int, error) {
+	if err := checkEntityType(entityType); err != nil {
+		return -1, err
+	}
+
+	slot, err := firstFreeSlot(len(block.Arrays.InvisibleObjects), block.isHiddenObjectFree, "hidden object")
+
+	if err != nil {
+		return slot, err
+	}
+
+	block.Arrays.InvisibleObjects[slot] = InvisibleObject{Type: uint32(entityType), Handle: handle}
+	return slot, nil
+}
+
+func (block *scriptBlock) RemoveHiddenObject(slot int) error {
+	if err := checkSlot(slot, len(block.Arrays.InvisibleObjects), "hidden object"); err != nil {
+		return err
+	}
+
+	block.Arrays.InvisibleObjects[slot] = InvisibleObject{}
+	return nil
+}
+
+// Suppressed vehicle models.
+
+func (block *scriptBlock) isSuppressedModelFree(slot int) bool {
+	return block.Arrays.SuppressedVehicleModels[slot] == freeModelId
+}
+
+func (block *scriptBlock) SuppressedModelSlots() []int {
+	return usedSlots(len(block.Arrays.SuppressedVehicleModels), block.isSuppressedModelFree)
+}
+
+// Stops vehicles with model `modelId` from being generated.
+func (block *scriptBlock) AddSuppressedModel(modelId int32) (int, error) {
+	if modelId < 0 {
+		return -1, fmt.Errorf("bad model %d", modelId)
+	}
+
+	for _, slot := range block.SuppressedModelSlots() {
+		if block.Arrays.SuppressedVehicleModels[slot] == uint32(modelId) {
+			return slot, fmt.Errorf("model %d is already suppressed", modelId)
+		}
+	}
+
+	slot, err := firstFreeSlot(len(block.Arrays.SuppressedVehicleModels), block.isSuppressedModelFree, "suppressed model")
+
+	if err != nil {
+		return slot, err
+	}
+
+	block.Arrays.SuppressedVehicleModels[slot] = uint32(modelId)
+	return slot, nil
+}
+
+func (block *scriptBlock) RemoveSuppressedModel(slot int) error {
+	if err := checkSlot(slot, len(block.Arrays.SuppressedVehicleModels), "suppressed model"); err != nil {
+		return err
+	}
+
+	block.Arrays.SuppressedVehicleModels[slot] = freeModelId
+	return nil
+}
+
+// LOD assignments.
+
+func (block *scriptBlock) isLodAssignmentFree(slot int) bool {
+	return block.Arrays.LodAssignments[slot].IsFree()
+}
+
+func (block *scriptBlock) LodAssignmentSlots() []int {
+	return usedSlots(len(block.Arrays.LodAssignments), block.isLodAssignmentFree)
+}
+
+// Makes the object with handle `lodHandle` the LOD of the object with handle `objectHandle`.
+func (block *scriptBlock) AddLodAssignment(objectHandle uint32, lodHandle uint32) (int, error) {
+	if objectHandle == 0 || objectHandle == freeHandle {
+		return -1, fmt.Errorf("bad object handle %d", objectHandle)
+	}
+
+	slot, err := firstFreeSlot(len(block.Arrays.LodAssignments), block.isLodAssignmentFree, "LOD assignment")
+
+	if err != nil {
+		return slot, err
+	}
+
+	block.Arrays.LodAssignments[slot] = LodAssignment{ObjectHandle: objectHandle, LodHandle: lodHandle}
+	return slot, nil
+}
+
+func (block *scriptBlock) RemoveLodAssignment(slot int) error {
+	if err := checkSlot(slot, len(block.Arrays.LodAssignments), "LOD assignment"); err != nil {
+		return err
+	}
+
+	block.Arrays.LodAssignments[slot] = LodAssignment{ObjectHandle: freeHandle, LodHandle: freeHandle}
+	return nil
+}
+
+// Script assignments.
+
+func (block *scriptBlock) isScriptAssignmentFree(slot int) bool {
+	return block.Arrays.ScriptAssignments[slot].IsFree()
+}
+
+func (block *scriptBlock) ScriptAssignmentSlots() []int {
+	return usedSlots(len(block.Arrays.ScriptAssignments), block.isScriptAssignmentFree)
+}
+
+// Assigns `name` to actors with model `modelId`.
+func (block *scriptBlock) AddScriptAssignment(modelId int32, name string) (int, error) {
+	if modelId < 0 {
+		return -1, fmt.Errorf("bad model %d", modelId)
+	}
+
+	assignment := ScriptAssignment{ActorModelId: uint32(modelId)}
+
+	if err := assignment.SetName(name, RejectLong); err != nil {
+		return -1, err
+	}
+
+	slot, err := firstFreeSlot(len(block.Arrays.ScriptAssignments), block.isScriptAssignmentFree, "script assignment")
+
+	if err != nil {
+		return slot, err
+	}
+
+	block.Arrays.ScriptAssignments[slot] = assignment
+	return slot, nil
+}
+
+func (block *scriptBlock) RemoveScriptAssignment(slot int) error {
+	if err := checkSlot(slot, len(block.Arrays.ScriptAssignments), "script assignment"); err != nil {
+		return err
+	}
+
+	block.Arrays.ScriptAssignments[slot] = ScriptAssignment{ActorModelId: freeModelId}
+	return nil
+}
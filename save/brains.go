@@ -0,0 +1,140 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Brains are scripts from script.img that the game starts by itself, either when a ped or
+// object with a particular model streams in, or when code (or an attractor) asks for the
+// script by name.
+
+// The largest activation chance a ped brain can have (it's a percentage).
+const maxActivationChance = 100
+
+func (theBrain *brain) IsInUse() bool {
+	return theBrain.General.AttachType != attachNotInUse
+}
+
+func (theBrain *brain) IsObjectBrain() bool {
+	return theBrain.General.AttachType == attachScriptToObject
+}
+
+func (theBrain *brain) IsPedBrain() bool {
+	return theBrain.General.AttachType == attachScriptToPed
+}
+
+// Returns a description of what the brain is attached to.
+func (theBrain *brain) AttachTypeName() string {
+	switch theBrain.General.AttachType {
+	case attachScriptToPed:
+		return "ped"
+	case attachScriptToObject:
+		return "object"
+	case attachBrainForCodeUse:
+		return "code"
+	case attachBrokenCodeUse:
+		return "broken code"
+	case attachAttractorScript:
+		return "attractor"
+	case attachNotInUse:
+		return "unused"
+	}
+
+	return fmt.Sprintf("unknown (%d)", theBrain.General.AttachType)
+}
+
+// Creates a brain that starts the streamed script `scriptIndex` when a ped (or object, if
+// `isObject` is set) using model `modelId` streams in within `radius` of the player.
+func NewModelBrain(isObject bool, scriptIndex uint16, modelId uint16, activationChance uint16, radius float32) (brain, error) {
+	theBrain := brain{}
+
+	if activationChance > maxActivationChance {
+		return theBrain, fmt.Errorf("activation chance must be a percentage, not %d", activationChance)
+	}
+
+	if radius <= 0 {
+		return theBrain, errors.New("radius must be positive")
+	}
+
+	theBrain.General.Index = scriptIndex
+	theBrain.General.AttachType = attachScriptToPed
+	theBrain.General.Radius = savedFloat(radius)
+
+	if isObject {
+		theBrain.General.AttachType = attachScriptToObject
+	}
+
+	theBrain.PedOrObject.ModelId = modelId
+	theBrain.PedOrObject.ActivationChance = activationChance
+
+	return theBrain, nil
+}
+
+// Creates a brain that starts the streamed script `scriptIndex` when code (or an attractor,
+// if `isAttractor` is set) asks for a script called `name`.
+func NewNamedBrain(isAttractor bool, scriptIndex uint16, name string) (brain, error) {
+	theBrain := brain{}
+
+	theBrain.General.Index = scriptIndex
+	theBrain.General.AttachType = attachBrainForCodeUse
+
+	if isAttractor {
+		theBrain.General.AttachType = attachAttractorScript
+	}
+
+	return theBrain, theBrain.SetScriptName(name, RejectLong)
+}
+
+// Returns the slots of the brains that are in use.
+func (block *scriptBlock) UsedBrainSlots() []int {
+	slots := []int{}
+
+	for slot := range block.Brains {
+		if block.Brains[slot].IsInUse() {
+			slots = append(slots, slot)
+		}
+	}
+
+	return slots
+}
+
+func (block *scriptBlock) BrainAt(slot int) (*brain, error) {
+	if slot < 0 || slot >= len(block.Brains) {
+		return nil, fmt.Errorf("brain slot %d does not exist (there are %d slots)", slot, len(block.Brains))
+	}
+
+	return &block.Brains[slot], nil
+}
+
+// Replaces the brain in slot `slot`.
+func (block *scriptBlock) SetBrain(slot int, theBrain brain) error {
+	existing, err := block.BrainAt(slot)
+
+	if err != nil {
+		return err
+	}
+
+	*existing = theBrain
+	return nil
+}
+
+// Puts the brain in the first free slot, and returns the slot.
+func (block *scriptBlock) AddBrain(theBrain brain) (int, error) {
+	for slot := range block.Brains {
+		if !block.Brains[slot].IsInUse() {
+			block.Brains[slot] = theBrain
+			return slot, nil
+		}
+	}
+
+	return -1, fmt.Errorf("all %d brain slots are in use", len(block.Brains))
+}
+
+// Frees the brain in slot `slot`.
+func (block *scriptBlock) RemoveBrain(slot int) error {
+	unused := brain{}
+	unused.General.AttachType = attachNotInUse
+
+	return block.SetBrain(slot, unused)
+}
@@ -3,7 +3,9 @@ package save
 import (
 	"encoding/binary"
 	"io"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -90,7 +92,61 @@ type padding uint8
 type boolPadding [3]uint8
 
 type vector3 struct {
-	X, Y, Z float32
+	X, Y, Z savedFloat
+}
+
+// Returns the raw representation of an integer script variable.
+func IntValue(value int32) uint32 {
+	return uint32(value)
+}
+
+// Splits an integer into its digits and base. Integers are decimal unless they start with
+// "0x", so a leading zero doesn't make them octal.
+func integerDigits(str string) (string, int, error) {
+	sign, digits := "", str
+
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+
+	if !strings.HasPrefix(digits, "0x") && !strings.HasPrefix(digits, "0X") {
+		return sign + digits, 10, nil
+	}
+
+	digits = digits[2:]
+
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		return "", 0, strconv.ErrSyntax
+	}
+
+	return sign + digits, 16, nil
+}
+
+// Parses a signed integer, written in decimal or in hexadecimal with a "0x" prefix.
+func ParseInteger(str string, bitSize int) (int64, error) {
+	digits, base, err := integerDigits(str)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(digits, base, bitSize)
+}
+
+// Parses an unsigned integer, written in decimal or in hexadecimal with a "0x" prefix.
+func ParseUnsigned(str string, bitSize int) (uint64, error) {
+	digits, base, err := integerDigits(str)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(digits, base, bitSize)
+}
+
+// Returns the raw representation of a floating-point script variable.
+func FloatValue(value float32) uint32 {
+	return math.Float32bits(value)
 }
 
 func mustRead(file io.Reader, data interface{}) {
@@ -111,5 +167,9 @@ func mustWrite(file io.Writer, data interface{}) {
 
 func nullTerminate(str *string) {
 	index := strings.IndexRune(*str, '\x00')
-	*str = (*str)[:index]
+
+	// Strings that fill their whole field have no terminator.
+	if index >= 0 {
+		*str = (*str)[:index]
+	}
 }
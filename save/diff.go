@@ -0,0 +1,244 @@
+package save
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Shown in place of a value that only one of the saves has.
+const absentValue = "(none)"
+
+// The most bytes of a changed range that are shown.
+const shownByteCount = 16
+
+// The size of the checksum at the end of the remainder. It changes whenever anything else
+// does, so it isn't compared.
+const checksumSize = 4
+
+// A value that differs between two saves. The path is in the form that `Get` takes, except
+// for byte ranges, which are written as "[0x<start>:0x<end>]". If the value is somewhere else
+// in the new save (because a running script moved), `NewPath` is where it is there.
+type FieldChange struct {
+	Path    string
+	NewPath string `json:",omitempty"`
+	Old     string
+	New     string
+}
+
+var scriptListType = reflect.TypeOf([]script{})
+
+// Returns every value that differs between `old` and `new`. Running scripts are matched by
+// name rather than by position, so that adding or removing a script only shows up once. The
+// platform and the checksum aren't compared.
+func Diff(old *File, new *File) []FieldChange {
+	changes := diffValues("vars", reflect.ValueOf(old.Vars), reflect.ValueOf(new.Vars), []FieldChange{})
+	changes = diffValues("scripts", reflect.ValueOf(old.Scripts), reflect.ValueOf(new.Scripts), changes)
+	return diffBytes("remainder", withoutChecksum(old.Remainder), withoutChecksum(new.Remainder), changes)
+}
+
+func withoutChecksum(remainder []byte) []byte {
+	if len(remainder) < checksumSize {
+		return remainder
+	}
+
+	return remainder[:len(remainder)-checksumSize]
+}
+
+func formatChangedValue(value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return strconv.Quote(value.String())
+	}
+
+	text, _ := formatScalar(value)
+	return text
+}
+
+func diffValues(path string, old reflect.Value, new reflect.Value, changes []FieldChange) []FieldChange {
+	if oldText, ok := formatScalar(old); ok {
+		if newText, _ := formatScalar(new); oldText != newText {
+			changes = append(changes, FieldChange{Path: path, Old: formatChangedValue(old), New: formatChangedValue(new)})
+		}
+
+		return changes
+	}
+
+	switch {
+	case old.Type() == scriptListType:
+		return diffScripts(path, old.Interface().([]script), new.Interface().([]script), changes)
+
+	case old.Kind() == reflect.Slice && old.Type().Elem().Kind() == reflect.Uint8:
+		return diffBytes(path, old.Bytes(), new.Bytes(), changes)
+
+	case old.Kind() == reflect.Struct:
+		for i := 0; i < old.NumField(); i++ {
+			if field := old.Type().Field(i); field.PkgPath == "" {
+				changes = diffValues(path+"."+field.Name, old.Field(i), new.Field(i), changes)
+			}
+		}
+
+	case old.Kind() == reflect.Slice || old.Kind() == reflect.Array:
+		commonLength := old.Len()
+
+		if new.Len() < commonLength {
+			commonLength = new.Len()
+		}
+
+		for i := 0; i < commonLength; i++ {
+			changes = diffValues(fmt.Sprintf("%s[%d]", path, i), old.Index(i), new.Index(i), changes)
+		}
+
+		if old.Len() == new.Len() {
+			break
+		}
+
+		changes = append(changes, FieldChange{Path: path, Old: fmt.Sprintf("%d elements", old.Len()), New: fmt.Sprintf("%d elements", new.Len())})
+
+		// Only list the extra elements that have something in them, since global space is
+		//  often expanded with zeros.
+		for i := commonLength; i < old.Len(); i++ {
+			if !old.Index(i).IsZero() {
+				changes = appendOneSided(fmt.Sprintf("%s[%d]", path, i), old.Index(i), true, changes)
+			}
+		}
+
+		for i := commonLength; i < new.Len(); i++ {
+			if !new.Index(i).IsZero() {
+				changes = appendOneSided(fmt.Sprintf("%s[%d]", path, i), new.Index(i), false, changes)
+			}
+		}
+	}
+
+	return changes
+}
+
+// Adds every value under `value`, which only the old save (or only the new save) has.
+func appendOneSided(path string, value reflect.Value, inOld bool, changes []FieldChange) []FieldChange {
+	for _, pathValue := range collectPathValues(value, path, []PathValue{}) {
+		change := FieldChange{Path: pathValue.Path, Old: absentValue, New: pathValue.Value}
+
+		if inOld {
+			change.Old, change.New = pathValue.Value, absentValue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func formatBytes(bytes []byte) string {
+	if len(bytes) > shownByteCount {
+		return hex.EncodeToString(bytes[:shownByteCount]) + "..."
+	}
+
+	return hex.EncodeToString(bytes)
+}
+
+// Adds a change for each run of bytes that differs. If the lengths differ, the extra bytes
+// are reported as one more run.
+func diffBytes(path string, old []byte, new []byte, changes []FieldChange) []FieldChange {
+	commonLength := len(old)
+
+	if len(new) < commonLength {
+		commonLength = len(new)
+	}
+
+	for start := 0; start < commonLength; start++ {
+		if old[start] == new[start] {
+			continue
+		}
+
+		end := start
+
+		for end < commonLength && old[end] != new[end] {
+			end++
+		}
+
+		changes = append(changes, FieldChange{Path: fmt.Sprintf("%s[0x%x:0x%x]", path, start, end),
+			Old: formatBytes(old[start:end]), New: formatBytes(new[start:end])})
+
+		start = end
+	}
+
+	if len(old) != len(new) {
+		change := FieldChange{Path: fmt.Sprintf("%s[0x%x:]", path, commonLength), Old: absentValue, New: absentValue}
+
+		if len(old) > commonLength {
+			change.Old = formatBytes(old[commonLength:])
+		} else {
+			change.New = formatBytes(new[commonLength:])
+		}
+
+		changes = append(changes, FieldChange{Path: path, Old: fmt.Sprintf("%d bytes", len(old)), New: fmt.Sprintf("%d bytes", len(new))}, change)
+	}
+
+	return changes
+}
+
+func countScriptsNamed(scripts []script, name string) int {
+	count := 0
+
+	for i := range scripts {
+		if scripts[i].Name == name {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Returns the path to a running script. Scripts are named if the name picks them out in
+// both saves, and given by their index in the save that has them otherwise.
+func scriptPath(path string, old []script, new []script, name string, index int) string {
+	if countScriptsNamed(old, name) <= 1 && countScriptsNamed(new, name) <= 1 {
+		return fmt.Sprintf("%s[name=%s]", path, name)
+	}
+
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+func diffScripts(path string, old []script, new []script, changes []FieldChange) []FieldChange {
+	matched := make([]bool, len(new))
+
+	for i := range old {
+		match := -1
+
+		// Scripts with the same name are matched up in order.
+		for j := range new {
+			if !matched[j] && new[j].Name == old[i].Name {
+				match = j
+				break
+			}
+		}
+
+		label := scriptPath(path, old, new, old[i].Name, i)
+
+		if match < 0 {
+			changes = append(changes, FieldChange{Path: label, Old: fmt.Sprintf("script '%s'", old[i].Name), New: absentValue})
+			continue
+		}
+
+		matched[match] = true
+		firstChange := len(changes)
+		changes = diffValues(label, reflect.ValueOf(old[i]), reflect.ValueOf(new[match]), changes)
+
+		// Scripts that share a name are given by index, which may be different in the new
+		//  save.
+		if newLabel := scriptPath(path, old, new, new[match].Name, match); newLabel != label {
+			for k := firstChange; k < len(changes); k++ {
+				changes[k].NewPath = newLabel + strings.TrimPrefix(changes[k].Path, label)
+			}
+		}
+	}
+
+	for j := range new {
+		if !matched[j] {
+			changes = append(changes, FieldChange{Path: scriptPath(path, old, new, new[j].Name, j), Old: absentValue, New: fmt.Sprintf("script '%s'", new[j].Name)})
+		}
+	}
+
+	return changes
+}
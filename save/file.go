@@ -0,0 +1,114 @@
+package save
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A whole save file. Only the first two blocks are parsed, and everything after them is
+// kept as it is.
+type File struct {
+	Platform GamePlatform
+	Vars     varBlock
+	Scripts  scriptBlock
+
+	// Everything after the script block, including the checksum at the end of the file.
+	Remainder []byte
+}
+
+func ReadFile(file *os.File) File {
+	save := File{}
+
+	save.Platform = NewGamePlatform(file)
+	save.Vars = ReadVarBlock(&save.Platform, file)
+	save.Scripts = ReadScriptBlock(&save.Platform, file)
+
+	remainder, err := io.ReadAll(file)
+
+	if err != nil {
+		panic(err)
+	}
+
+	save.Remainder = remainder
+	return save
+}
+
+// Reads a save like ReadFile, but returns an error instead of panicking if the file isn't a
+// save that can be parsed.
+func ReadFileChecked(file *os.File) (save File, err error) {
+	identifier := make([]byte, 5)
+
+	if _, err := file.ReadAt(identifier, 0); err != nil || string(identifier) != "BLOCK" {
+		return File{}, fmt.Errorf("the file doesn't start with a save block")
+	}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			save, err = File{}, fmt.Errorf("the save can't be parsed: %v", recovered)
+		}
+	}()
+
+	return ReadFile(file), nil
+}
+
+// Returns the length that saves for the platform are written with. Only the PC length is
+// known; other platforms use a guess.
+func (platform *GamePlatform) SaveLength() int {
+	if platform.IsPC {
+		return 202752
+	}
+
+	// TODO: Target length for PS2 platform.
+	return 195000
+}
+
+// Writes the save, fixing the length and checksum.
+func WriteFile(file io.Writer, save *File) error {
+	targetLength := save.Platform.SaveLength()
+	buffer := bytes.NewBuffer(make([]byte, 0, targetLength))
+
+	if err := WriteVarBlock(&save.Platform, buffer, &save.Vars); err != nil {
+		return fmt.Errorf("unable to write variable block: %v", err)
+	}
+
+	if err := WriteScriptBlock(&save.Platform, buffer, &save.Scripts); err != nil {
+		return fmt.Errorf("unable to write script block: %v", err)
+	}
+
+	buffer.Write(save.Remainder)
+
+	length := buffer.Len()
+
+	// Saves that have grown are cut back to the fixed length, which is only safe if
+	//  everything cut off is padding. The last 4 bytes are the old checksum, which is
+	//  replaced anyway.
+	if length > targetLength && (save.Platform.IsPC || save.Platform.IsMobile) {
+		for _, value := range buffer.Bytes()[targetLength-4 : length-4] {
+			if value != 0 {
+				return fmt.Errorf("the save would be %d bytes too long, and there isn't enough padding at the end to remove", length-targetLength)
+			}
+		}
+	}
+
+	if save.Platform.IsPC || (save.Platform.IsMobile && length > targetLength) {
+		length = targetLength
+	}
+
+	finalBytes := buffer.Bytes()[:length-4]
+
+	var checksum uint32 = 0
+	for _, value := range finalBytes {
+		checksum += uint32(value)
+	}
+
+	checksumBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksumBytes, checksum)
+
+	finalBytes = append(finalBytes, checksumBytes...)
+
+	_, err := file.Write(finalBytes)
+	return err
+}
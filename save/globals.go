@@ -0,0 +1,440 @@
+package save
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The type of value that a global variable holds. Saves don't store this, so it comes
+// from the symbol map.
+type GlobalType int
+
+const (
+	GlobalInt GlobalType = iota
+	GlobalFloat
+)
+
+func (globalType GlobalType) ToString() string {
+	if globalType == GlobalFloat {
+		return "float"
+	}
+
+	return "int"
+}
+
+func parseGlobalType(str string) (GlobalType, error) {
+	switch strings.ToLower(strings.TrimSpace(str)) {
+	case "", "int", "integer":
+		return GlobalInt, nil
+	case "float":
+		return GlobalFloat, nil
+	}
+
+	return GlobalInt, fmt.Errorf("unknown global type '%s'", str)
+}
+
+// A named global variable. `Index` is the variable's index in global storage, which is the
+// number used for the variable in Sanny Builder (so $2 is index 2).
+type GlobalSymbol struct {
+	Name  string
+	Index int
+	Type  GlobalType
+}
+
+// Formats the raw value of the global according to the global's type.
+func (symbol GlobalSymbol) Format(value uint32) string {
+	if symbol.Type == GlobalFloat {
+		return strconv.FormatFloat(float64(math.Float32frombits(value)), 'g', -1, 32)
+	}
+
+	return strconv.Itoa(int(int32(value)))
+}
+
+// Parses a value for the global according to the global's type.
+func (symbol GlobalSymbol) Parse(str string) (uint32, error) {
+	if symbol.Type == GlobalFloat {
+		floating, err := strconv.ParseFloat(strings.TrimSpace(str), 32)
+
+		if err != nil {
+			return 0, fmt.Errorf("'%s' is not a float", str)
+		}
+
+		return FloatValue(float32(floating)), nil
+	}
+
+	integer, err := ParseInteger(strings.TrimSpace(str), 32)
+
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not an integer", str)
+	}
+
+	return IntValue(int32(integer)), nil
+}
+
+// Maps between global variable names and indices.
+type SymbolMap struct {
+	byName  map[string]GlobalSymbol
+	byIndex map[int]GlobalSymbol
+}
+
+func NewSymbolMap() *SymbolMap {
+	return &SymbolMap{byName: map[string]GlobalSymbol{}, byIndex: map[int]GlobalSymbol{}}
+}
+
+func (symbols *SymbolMap) Add(symbol GlobalSymbol) error {
+	if symbol.Index < 0 {
+		return fmt.Errorf("global '%s' has a negative index", symbol.Name)
+	}
+
+	key := strings.ToLower(symbol.Name)
+
+	if existing, found := symbols.byName[key]; found && existing.Index != symbol.Index {
+		return fmt.Errorf("global '%s' is defined as both %d and %d", symbol.Name, existing.Index, symbol.Index)
+	}
+
+	symbols.byName[key] = symbol
+	symbols.byIndex[symbol.Index] = symbol
+
+	return nil
+}
+
+// Finds a global by name. Names are compared without case, and may start with '$' as they
+// do in Sanny Builder.
+func (symbols *SymbolMap) Lookup(name string) (GlobalSymbol, bool) {
+	symbol, found := symbols.byName[strings.ToLower(strings.TrimPrefix(name, "$"))]
+	return symbol, found
+}
+
+func (symbols *SymbolMap) AtIndex(index int) (GlobalSymbol, bool) {
+	symbol, found := symbols.byIndex[index]
+	return symbol, found
+}
+
+// Returns the symbol for a global given by name or by index. Globals without a symbol are
+// given a placeholder name and treated as integers.
+func (symbols *SymbolMap) Resolve(nameOrIndex string) (GlobalSymbol, error) {
+	if index, err := strconv.Atoi(strings.TrimPrefix(nameOrIndex, "$")); err == nil {
+		if symbol, found := symbols.AtIndex(index); found {
+			return symbol, nil
+		}
+
+		return GlobalSymbol{Name: fmt.Sprintf("$%d", index), Index: index}, nil
+	}
+
+	if symbol, found := symbols.Lookup(nameOrIndex); found {
+		return symbol, nil
+	}
+
+	return GlobalSymbol{}, fmt.Errorf("unknown global '%s'", nameOrIndex)
+}
+
+// Loads `index=name` lines, as found in Sanny Builder's CustomVariables.ini. A type can be
+// given after the name (e.g. `12=SPEED,float`); otherwise globals are integers.
+func (symbols *SymbolMap) loadIni(reader io.Reader) error {
+	fileBytes, err := io.ReadAll(reader)
+
+	if err != nil {
+		return err
+	}
+
+	for lineNumber, dirtyLine := range strings.Split(string(fileBytes), "\n") {
+		if commentIndex := strings.IndexRune(dirtyLine, ';'); -1 < commentIndex {
+			dirtyLine = dirtyLine[:commentIndex]
+		}
+
+		line := strings.TrimSpace(dirtyLine)
+
+		// Skip blank lines and section headers.
+		if len(line) == 0 || line[0] == '[' {
+			continue
+		}
+
+		equalsIndex := strings.IndexRune(line, '=')
+
+		if equalsIndex < 0 {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimSpace(line[:equalsIndex]))
+
+		if err != nil {
+			return fmt.Errorf("line %d: bad index in '%s'", lineNumber+1, line)
+		}
+
+		fields := strings.SplitN(line[equalsIndex+1:], ",", 2)
+		symbol := GlobalSymbol{Name: strings.TrimSpace(fields[0]), Index: index}
+
+		if len(fields) == 2 {
+			if symbol.Type, err = parseGlobalType(fields[1]); err != nil {
+				return fmt.Errorf("line %d: %v", lineNumber+1, err)
+			}
+		}
+
+		if err := symbols.Add(symbol); err != nil {
+			return fmt.Errorf("line %d: %v", lineNumber+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Loads `name,index,type` records. The type can be left out, and a header row is skipped.
+func (symbols *SymbolMap) loadCsv(reader io.Reader) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.Comment = '#'
+
+	records, err := csvReader.ReadAll()
+
+	if err != nil {
+		return err
+	}
+
+	for recordNumber, record := range records {
+		if len(record) < 2 {
+			return fmt.Errorf("record %d: expected at least a name and an index", recordNumber+1)
+		}
+
+		index, err := strconv.Atoi(strings.TrimSpace(record[1]))
+
+		if err != nil {
+			// Allow a header row.
+			if recordNumber == 0 {
+				continue
+			}
+
+			return fmt.Errorf("record %d: bad index '%s'", recordNumber+1, record[1])
+		}
+
+		symbol := GlobalSymbol{Name: strings.TrimSpace(record[0]), Index: index}
+
+		if len(record) > 2 {
+			if symbol.Type, err = parseGlobalType(record[2]); err != nil {
+				return fmt.Errorf("record %d: %v", recordNumber+1, err)
+			}
+		}
+
+		if err := symbols.Add(symbol); err != nil {
+			return fmt.Errorf("record %d: %v", recordNumber+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Loads symbols from a file. Files ending in .ini are read as Sanny Builder variable lists,
+// and anything else is read as CSV.
+func (symbols *SymbolMap) Load(path string) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".ini") {
+		err = symbols.loadIni(file)
+	} else {
+		err = symbols.loadCsv(file)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	return nil
+}
+
+func (block *scriptBlock) Global(index int) (uint32, error) {
+	if index < 0 || index >= len(block.GlobalStorage.Globals) {
+		return 0, fmt.Errorf("global %d is outside global storage (%d variables)", index, len(block.GlobalStorage.Globals))
+	}
+
+	return block.GlobalStorage.Globals[index], nil
+}
+
+// Sets a global variable. Globals before `FirstGlobalVariable` can't be set, since they hold
+// the jump over global space.
+func (block *scriptBlock) SetGlobal(index int, value uint32) error {
+	if _, err := block.Global(index); err != nil {
+		return err
+	}
+
+	if index < FirstGlobalVariable {
+		return fmt.Errorf("global %d holds the jump over global space, not a variable", index)
+	}
+
+	block.GlobalStorage.Globals[index] = value
+	return nil
+}
+
+// Returns the symbol and value of a global given by name or by index.
+func (block *scriptBlock) NamedGlobal(symbols *SymbolMap, nameOrIndex string) (GlobalSymbol, uint32, error) {
+	symbol, err := symbols.Resolve(nameOrIndex)
+
+	if err != nil {
+		return symbol, 0, err
+	}
+
+	value, err := block.Global(symbol.Index)
+	return symbol, value, err
+}
+
+// Sets a global given by name or by index, parsing `value` according to the global's type.
+func (block *scriptBlock) SetNamedGlobal(symbols *SymbolMap, nameOrIndex string, value string) error {
+	symbol, err := symbols.Resolve(nameOrIndex)
+
+	if err != nil {
+		return err
+	}
+
+	rawValue, err := symbol.Parse(value)
+
+	if err != nil {
+		return fmt.Errorf("%s: %v", symbol.Name, err)
+	}
+
+	return block.SetGlobal(symbol.Index, rawValue)
+}
+
+// Globals 0 and 1 hold the jump over global space at the start of main.scm, so they aren't
+// really variables.
+const FirstGlobalVariable = 2
+
+// The most bytes that global space can take up. Global space is loaded over the start of the
+// space reserved for main.scm, so it can't be any bigger than that.
+const GlobalSpaceLimit = missionBaseOffset
+
+// Returns the index of the first global that holds code a running script is at, or the
+// number of globals if no script is running code in global space. Everything from there on
+// is usually embedded code rather than variables. Code before the point the script is at
+// (such as code before its entry point) can't be found this way.
+func (block *scriptBlock) EmbeddedCodeStart() int {
+	start := len(block.GlobalStorage.Globals)
+
+	for i := range block.Running.RunningScripts {
+		theScript := &block.Running.RunningScripts[i]
+
+		if theScript.IsMissionScript() || theScript.Info.IsExternal {
+			continue
+		}
+
+		pointer := theScript.Info.RelativeInstructionPointer
+
+		if pointer >= FirstGlobalVariable*4 && pointer < block.GlobalStorage.GlobalSpaceSize && int(pointer/4) < start {
+			start = int(pointer / 4)
+		}
+	}
+
+	return start
+}
+
+// A global that has different values in two saves.
+type GlobalChange struct {
+	Index int
+	Old   uint32
+	New   uint32
+}
+
+// Returns the globals that differ between `block` and `other`, up to (but not including)
+// global `limit`. Globals that only one of the blocks has are ignored, because they're
+// usually where code has been embedded.
+func (block *scriptBlock) CompareGlobals(other *scriptBlock, limit int) []GlobalChange {
+	changes := []GlobalChange{}
+
+	if len(block.GlobalStorage.Globals) < limit {
+		limit = len(block.GlobalStorage.Globals)
+	}
+
+	if len(other.GlobalStorage.Globals) < limit {
+		limit = len(other.GlobalStorage.Globals)
+	}
+
+	for index := FirstGlobalVariable; index < limit; index++ {
+		oldValue, newValue := block.GlobalStorage.Globals[index], other.GlobalStorage.Globals[index]
+
+		if oldValue != newValue {
+			changes = append(changes, GlobalChange{index, oldValue, newValue})
+		}
+	}
+
+	return changes
+}
+
+// Checks that `length` bytes of code can be put at `position` in global space without
+// overwriting the jump at the start of global space or code that a running script is using.
+func (block *scriptBlock) checkGlobalRegion(position uint32, length uint32) error {
+	const jumpSize = FirstGlobalVariable * 4
+
+	if position%4 != 0 {
+		return fmt.Errorf("offset %d in global space is not aligned to a variable", position)
+	}
+
+	if position < jumpSize {
+		return fmt.Errorf("offset %d would overwrite the jump at the start of global space", position)
+	}
+
+	// Work in 64 bits so that a position near the top of the range can't wrap around.
+	end := uint64(position) + uint64(length)
+
+	if end > uint64(block.GlobalStorage.GlobalSpaceSize) {
+		return fmt.Errorf("the code would end at %d, but global space is only %d bytes", end, block.GlobalStorage.GlobalSpaceSize)
+	}
+
+	for i := range block.Running.RunningScripts {
+		theScript := &block.Running.RunningScripts[i]
+
+		if theScript.IsMissionScript() || theScript.Info.IsExternal {
+			continue
+		}
+
+		if pointer := theScript.Info.RelativeInstructionPointer; position <= pointer && uint64(pointer) < end {
+			return fmt.Errorf("the code would overwrite running script '%s' at offset %d", theScript.Name, pointer)
+		}
+	}
+
+	return nil
+}
+
+// Copies `contents` into global space at `position`, which must already have been checked.
+func (block *scriptBlock) packGlobals(contents []byte, position uint32) {
+	for i := 0; i < len(contents); i += 4 {
+		availableCount := len(contents) - i
+
+		if availableCount > 4 {
+			availableCount = 4
+		}
+
+		variableBytes := make([]byte, 4)
+
+		// Only copy in as many bytes as we have available. Any bytes we don't fill
+		//  will be zero, so the result is the same as if the source was padded to
+		//  a multiple of 4.
+		copy(variableBytes, contents[i:i+availableCount])
+
+		globalValue := binary.LittleEndian.Uint32(variableBytes)
+
+		// Divide by 4 to obtain the index of the variable which will hold these bytes.
+		globalIndex := (int(position) + i) / 4
+		block.GlobalStorage.Globals[globalIndex] = globalValue
+	}
+}
+
+// Puts `contents` in global space at `position` (a byte offset). The space must already
+// exist (see ExpandGlobalSpace), and the data can't overwrite code that a script is running.
+func (block *scriptBlock) EmbedData(contents []byte, position uint32) error {
+	if err := block.checkGlobalRegion(position, uint32(len(contents))); err != nil {
+		return err
+	}
+
+	block.packGlobals(contents, position)
+	return nil
+}
@@ -0,0 +1,141 @@
+package save
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// A float stored in a save. JSON has no NaN or infinity, and saves can have either (they're
+// just bits as far as the game is concerned), so those are written as their bits in a string
+// like "0x7fc00000". Other values are written as plain numbers.
+type savedFloat float32
+
+func (value savedFloat) MarshalJSON() ([]byte, error) {
+	floating := float64(value)
+
+	if math.IsNaN(floating) || math.IsInf(floating, 0) {
+		return json.Marshal(fmt.Sprintf("0x%08x", math.Float32bits(float32(value))))
+	}
+
+	return json.Marshal(float32(value))
+}
+
+func (value *savedFloat) UnmarshalJSON(data []byte) error {
+	var bitsText string
+
+	if json.Unmarshal(data, &bitsText) != nil {
+		return json.Unmarshal(data, (*float32)(value))
+	}
+
+	bits, err := ParseUnsigned(bitsText, 32)
+
+	if err != nil {
+		return fmt.Errorf("'%s' is not a number or the bits of one", bitsText)
+	}
+
+	*value = savedFloat(math.Float32frombits(uint32(bits)))
+	return nil
+}
+
+// The JSON form of a save. Some of the parsed values are unexported, so they're stored
+// alongside the blocks. Byte slices (the mission buffer and the remainder of the file) are
+// stored as base64.
+type fileJSON struct {
+	Platform GamePlatform
+
+	VarBlockIdentifier [5]uint8
+	Vars               varBlock
+
+	ScriptBlockIdentifier [5]uint8
+	Scripts               scriptBlock
+
+	// The number of locals used by each running script that was embedded, or zero for
+	//  scripts that weren't (or whose usage isn't known).
+	ScriptLocalCounts []uint32
+
+	Remainder []byte
+}
+
+func (save *File) MarshalJSON() ([]byte, error) {
+	contents := fileJSON{
+		Platform:              save.Platform,
+		VarBlockIdentifier:    save.Vars.blockIdentifier,
+		Vars:                  save.Vars,
+		ScriptBlockIdentifier: save.Scripts.blockIdentifier,
+		Scripts:               save.Scripts,
+		ScriptLocalCounts:     make([]uint32, len(save.Scripts.Running.RunningScripts)),
+		Remainder:             save.Remainder,
+	}
+
+	for i := range save.Scripts.Running.RunningScripts {
+		contents.ScriptLocalCounts[i] = save.Scripts.Running.RunningScripts[i].localCount
+	}
+
+	return json.Marshal(contents)
+}
+
+func (save *File) UnmarshalJSON(data []byte) error {
+	contents := fileJSON{}
+
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return err
+	}
+
+	loaded := File{
+		Platform:  contents.Platform,
+		Vars:      contents.Vars,
+		Scripts:   contents.Scripts,
+		Remainder: contents.Remainder,
+	}
+
+	loaded.Vars.blockIdentifier = contents.VarBlockIdentifier
+	loaded.Scripts.blockIdentifier = contents.ScriptBlockIdentifier
+
+	if err := loaded.Scripts.checkShape(&loaded.Platform); err != nil {
+		return err
+	}
+
+	for i := range loaded.Scripts.Running.RunningScripts {
+		if i < len(contents.ScriptLocalCounts) {
+			loaded.Scripts.Running.RunningScripts[i].localCount = contents.ScriptLocalCounts[i]
+		}
+	}
+
+	// The count is only there so that the game knows how many scripts to read, so it can
+	//  follow whatever scripts were added or removed.
+	loaded.Scripts.Values.RunningScriptCount = uint32(len(loaded.Scripts.Running.RunningScripts))
+	// The links are kept as they are, so that they can be checked (and only rebuilt with
+	//  `Relink` if the caller wants that).
+	loaded.Scripts.inferPool(&loaded.Platform)
+
+	*save = loaded
+	return nil
+}
+
+// Checks that the sizes of the block's variable-length parts match the rest of the block,
+// since they're written as they are.
+func (block *scriptBlock) checkShape(platform *GamePlatform) error {
+	if globalCount := len(block.GlobalStorage.Globals); uint32(globalCount)*4 != block.GlobalStorage.GlobalSpaceSize {
+		return fmt.Errorf("global space is %d bytes, but there are %d globals", block.GlobalStorage.GlobalSpaceSize, globalCount)
+	}
+
+	for i := range block.Running.RunningScripts {
+		theScript := &block.Running.RunningScripts[i]
+
+		if len(theScript.Locals) != platform.MaxLocals() {
+			return fmt.Errorf("running script %d has %d locals, but scripts on this platform have %d",
+				i, len(theScript.Locals), platform.MaxLocals())
+		}
+
+		if !theScript.IsMissionScript() {
+			continue
+		}
+
+		if len(theScript.Mission.MissionCode) != missionCodeSize || len(theScript.Mission.Locals) != MissionLocalCount {
+			return fmt.Errorf("mission script %d needs %d bytes of code and %d locals", i, missionCodeSize, MissionLocalCount)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Running scripts are allocated from a fixed pool. The game keeps the scripts that are in
+// use in a doubly-linked "active" list, and the rest of the pool makes up the "idle" list.
+// Saves contain the raw list pointers from the session that made the save, so we work out
+// where the pool was (and how big each entry is) from the pointers themselves.
+
+// The number of scripts in the pool.
+const scriptPoolSize = 96
+
+// The bit of a script's index that marks it as a mission script. The rest of the index is
+// the script's slot in the pool.
+const missionScriptFlag = 0x8000
+
+type scriptPool struct {
+	// Address of the first script in the pool.
+	base uint32
+
+	// Size of each script in the pool.
+	stride uint32
+
+	// Whether the save lists the scripts from the tail of the active list to the head.
+	reversed bool
+
+	// Whether `base` and `stride` were actually worked out from the save.
+	known bool
+}
+
+// A pointer to the script in pool slot `slot`.
+type poolPointer struct {
+	slot    uint32
+	address uint32
+}
+
+// Returns the script's slot in the pool.
+func (theScript *script) Slot() uint32 {
+	return uint32(theScript.Index &^ missionScriptFlag)
+}
+
+func (pool *scriptPool) addressOf(theScript *script) uint32 {
+	return pool.base + theScript.Slot()*pool.stride
+}
+
+// Returns the scripts that the script at `index` should link to, taking the order of the
+// list in the save into account.
+func (block *scriptBlock) linkedNeighbours(index int, reversed bool) (next *script, previous *script) {
+	scripts := block.Running.RunningScripts
+
+	nextIndex, previousIndex := index+1, index-1
+
+	if reversed {
+		nextIndex, previousIndex = previousIndex, nextIndex
+	}
+
+	if 0 <= nextIndex && nextIndex < len(scripts) {
+		next = &scripts[nextIndex]
+	}
+
+	if 0 <= previousIndex && previousIndex < len(scripts) {
+		previous = &scripts[previousIndex]
+	}
+
+	return next, previous
+}
+
+// Collects every pointer that should point to another script if the scripts are linked in
+// the order given by `reversed`.
+func (block *scriptBlock) linkPointers(reversed bool) []poolPointer {
+	pointers := []poolPointer{}
+
+	for i := range block.Running.RunningScripts {
+		theScript := &block.Running.RunningScripts[i]
+		next, previous := block.linkedNeighbours(i, reversed)
+
+		if next != nil {
+			pointers = append(pointers, poolPointer{next.Slot(), theScript.Link.PointerToNext})
+		}
+
+		if previous != nil {
+			pointers = append(pointers, poolPointer{previous.Slot(), theScript.Link.PointerToPrevious})
+		}
+	}
+
+	return pointers
+}
+
+// Works out the pool layout that agrees with the most pointers. Returns the layout and the
+// number of pointers that agree with it.
+func solvePool(pointers []poolPointer) (scriptPool, int) {
+	best, bestCount := scriptPool{}, 0
+
+	for _, first := range pointers {
+		for _, second := range pointers {
+			if first.slot <= second.slot || first.address <= second.address {
+				continue
+			}
+
+			addressDifference := first.address - second.address
+			slotDifference := first.slot - second.slot
+
+			if addressDifference%slotDifference != 0 {
+				continue
+			}
+
+			pool := scriptPool{stride: addressDifference / slotDifference, known: true}
+			pool.base = first.address - first.slot*pool.stride
+
+			count := 0
+
+			for _, pointer := range pointers {
+				if pointer.address == pool.base+pointer.slot*pool.stride {
+					count++
+				}
+			}
+
+			if count > bestCount {
+				best, bestCount = pool, count
+			}
+		}
+	}
+
+	return best, bestCount
+}
+
+// Works out the layout of the script pool from the links in the save. If there aren't
+// enough links to work it out, a layout is guessed so that new links can still be made.
+func (block *scriptBlock) inferPool(platform *GamePlatform) {
+	forwardPool, forwardCount := solvePool(block.linkPointers(false))
+	reversedPool, reversedCount := solvePool(block.linkPointers(true))
+
+	if forwardCount != 0 || reversedCount != 0 {
+		if reversedCount > forwardCount {
+			reversedPool.reversed = true
+			block.pool = reversedPool
+		} else {
+			block.pool = forwardPool
+		}
+
+		return
+	}
+
+	// The script structure is 224 bytes with 32 locals, and grows with the number of locals.
+	block.pool = scriptPool{stride: 224 + uint32(platform.MaxLocals()-32)*4}
+
+	// If there is a single pointer, we can still find the base.
+	if pointers := block.linkPointers(false); len(pointers) != 0 {
+		block.pool.base = pointers[0].address - pointers[0].slot*block.pool.stride
+	}
+}
+
+// Checks that every running script's link pointers point to its neighbours in the active
+// list, and that no two scripts share a pool slot.
+func (block *scriptBlock) ValidateLinks() error {
+	scripts := block.Running.RunningScripts
+
+	if len(scripts) > scriptPoolSize {
+		return fmt.Errorf("there are %d running scripts, but the pool only has space for %d", len(scripts), scriptPoolSize)
+	}
+
+	usedSlots := map[uint32]string{}
+
+	for _, theScript := range scripts {
+		if theScript.Slot() >= scriptPoolSize {
+			return fmt.Errorf("script '%s' is in slot %d, which is outside the pool", theScript.Name, theScript.Slot())
+		}
+
+		if otherName, used := usedSlots[theScript.Slot()]; used {
+			return fmt.Errorf("scripts '%s' and '%s' are both in slot %d", otherName, theScript.Name, theScript.Slot())
+		}
+
+		usedSlots[theScript.Slot()] = theScript.Name
+	}
+
+	if len(scripts) > 1 && !block.pool.known {
+		return errors.New("the links between running scripts are inconsistent")
+	}
+
+	for i := range scripts {
+		theScript := &scripts[i]
+		next, previous := block.linkedNeighbours(i, block.pool.reversed)
+
+		expectedNext, expectedPrevious := uint32(0), uint32(0)
+
+		if next != nil {
+			expectedNext = block.pool.addressOf(next)
+		}
+
+		if previous != nil {
+			expectedPrevious = block.pool.addressOf(previous)
+		}
+
+		if theScript.Link.PointerToNext != expectedNext {
+			return fmt.Errorf("script '%s' has next pointer 0x%x, expected 0x%x", theScript.Name, theScript.Link.PointerToNext, expectedNext)
+		}
+
+		if theScript.Link.PointerToPrevious != expectedPrevious {
+			return fmt.Errorf("script '%s' has previous pointer 0x%x, expected 0x%x", theScript.Name, theScript.Link.PointerToPrevious, expectedPrevious)
+		}
+	}
+
+	return nil
+}
+
+// Lets the links be rebuilt when scripts are added, removed or moved, even if the links in
+// the save are corrupt. The pool layout is a guess in that case, so the new links may not
+// match where the game put the scripts.
+func (block *scriptBlock) AllowRelinkingCorruptList() {
+	block.relinkCorrupt = true
+}
+
+// Checks that the links can be rebuilt without guessing at a list that was already wrong.
+func (block *scriptBlock) checkRelinkable() error {
+	if block.relinkCorrupt {
+		return nil
+	}
+
+	if err := block.ValidateLinks(); err != nil {
+		return fmt.Errorf("the running script list is corrupt, so it won't be relinked: %v", err)
+	}
+
+	return nil
+}
+
+// Rebuilds the links from the order of the running scripts, for when scripts have been
+// added, removed or reordered by hand. This fails if the links were already corrupt, unless
+// `AllowRelinkingCorruptList` has been called.
+func (block *scriptBlock) Relink() error {
+	if err := block.checkRelinkable(); err != nil {
+		return err
+	}
+
+	block.relinkScripts()
+	return nil
+}
+
+// Rebuilds the link pointers of every running script from the order of the scripts.
+func (block *scriptBlock) relinkScripts() {
+	for i := range block.Running.RunningScripts {
+		theScript := &block.Running.RunningScripts[i]
+		next, previous := block.linkedNeighbours(i, block.pool.reversed)
+
+		theScript.Link.PointerToNext = 0
+		theScript.Link.PointerToPrevious = 0
+
+		if next != nil {
+			theScript.Link.PointerToNext = block.pool.addressOf(next)
+		}
+
+		if previous != nil {
+			theScript.Link.PointerToPrevious = block.pool.addressOf(previous)
+		}
+	}
+
+	block.Values.RunningScriptCount = uint32(len(block.Running.RunningScripts))
+}
+
+// Returns the pool slots that are not used by any running script (the idle list).
+func (block *scriptBlock) FreeScriptSlots() []uint16 {
+	used := make([]bool, scriptPoolSize)
+
+	for i := range block.Running.RunningScripts {
+		if slot := block.Running.RunningScripts[i].Slot(); slot < scriptPoolSize {
+			used[slot] = true
+		}
+	}
+
+	free := []uint16{}
+
+	for slot, isUsed := range used {
+		if !isUsed {
+			free = append(free, uint16(slot))
+		}
+	}
+
+	return free
+}
+
+// Removes the running script at `index` and relinks the remaining scripts. Scripts that the
+// game needs can't be removed.
+func (block *scriptBlock) RemoveScript(index int) error {
+	scripts := block.Running.RunningScripts
+
+	if index < 0 || index >= len(scripts) {
+		return fmt.Errorf("there is no running script at index %d", index)
+	}
+
+	if err := scripts[index].checkNotRequired(); err != nil {
+		return err
+	}
+
+	if err := block.checkRelinkable(); err != nil {
+		return err
+	}
+
+	block.Running.RunningScripts = append(scripts[:index:index], scripts[index+1:]...)
+	block.relinkScripts()
+
+	return nil
+}
+
+// Moves the running script at `from` so that it is at `to`, and relinks the scripts.
+func (block *scriptBlock) MoveScript(from int, to int) error {
+	scripts := block.Running.RunningScripts
+
+	if from < 0 || from >= len(scripts) || to < 0 || to >= len(scripts) {
+		return fmt.Errorf("cannot move script %d to %d, as there are %d running scripts", from, to, len(scripts))
+	}
+
+	if err := block.checkRelinkable(); err != nil {
+		return err
+	}
+
+	moving := scripts[from]
+
+	if from < to {
+		copy(scripts[from:to], scripts[from+1:to+1])
+	} else {
+		copy(scripts[to+1:from+1], scripts[to:from])
+	}
+
+	scripts[to] = moving
+	block.relinkScripts()
+
+	return nil
+}
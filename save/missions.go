@@ -0,0 +1,97 @@
+package save
+
+import "fmt"
+
+// Mission scripts don't run from main.scm. Instead, the game loads them into a separate
+// buffer after main.scm in script space, and only one can be loaded at a time. Mission
+// scripts also get their own, much larger, set of locals. Saves made while a mission script
+// is running contain the whole buffer and the mission locals, so we can put code there
+// instead of in global space.
+
+const (
+	// The size of the mission buffer.
+	missionCodeSize = 69000
+
+	// The number of locals available to mission scripts.
+	MissionLocalCount = 1024
+
+	// Offset of the mission buffer in script space. This is the space reserved for main.scm,
+	//  which is fixed regardless of the size of the actual main.scm.
+	missionBaseOffset = 200000
+)
+
+// The number of bytes that a mission script takes up in a save on top of a normal script.
+const MissionBufferSize = missionCodeSize + MissionLocalCount*4
+
+// Returns the running mission script, or nil if there isn't one.
+func (block *scriptBlock) MissionScript() *script {
+	for i := range block.Running.RunningScripts {
+		if block.Running.RunningScripts[i].Index&missionScriptFlag != 0 {
+			return &block.Running.RunningScripts[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns the offset of `pointer` (relative to script space) from the start of the mission
+// buffer, or false if the pointer isn't in the mission buffer.
+func MissionOffset(pointer uint32) (uint32, bool) {
+	if pointer < missionBaseOffset || pointer >= missionBaseOffset+missionCodeSize {
+		return 0, false
+	}
+
+	return pointer - missionBaseOffset, true
+}
+
+// Adds a mission script that runs `contents` from the mission buffer. Jumps within the code
+// must be negative (relative to the start of the script), as they are in compiled missions,
+// so the code doesn't need relocating. Fails if there is already a mission script running.
+//
+// The game stores the whole mission buffer and its locals with a mission script, so this adds
+// MissionBufferSize bytes to the save however small the script is. The save must have that
+// much padding at the end, or it can't be written.
+func (block *scriptBlock) AddMissionScript(platform *GamePlatform, vars *varBlock, name string, contents []byte, options *ScriptOptions) error {
+	if options == nil {
+		options = &ScriptOptions{}
+	}
+
+	if len(contents) > missionCodeSize {
+		return fmt.Errorf("the script is %d bytes, but the mission buffer is only %d bytes", len(contents), missionCodeSize)
+	}
+
+	if existing := block.MissionScript(); existing != nil {
+		return fmt.Errorf("mission script '%s' is already running, and only one can run at a time", existing.Name)
+	}
+
+	if err := options.Validate(contents, MissionLocalCount, vars); err != nil {
+		return err
+	}
+
+	if err := block.checkRelinkable(); err != nil {
+		return err
+	}
+
+	theScript, err := block.newEmbeddedScript(platform, vars, name, missionBaseOffset, options)
+
+	if err != nil {
+		return err
+	}
+
+	theScript.Index |= missionScriptFlag
+	theScript.Info.IsMission = true
+
+	theScript.Mission.MissionCode = make([]uint8, missionCodeSize)
+	copy(theScript.Mission.MissionCode, contents)
+
+	theScript.Mission.Locals = make([]uint32, MissionLocalCount)
+
+	for index, value := range options.Locals {
+		theScript.Mission.Locals[index] = value
+	}
+
+	block.Running.RunningScripts = append(block.Running.RunningScripts, theScript)
+	block.relinkScripts()
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package save
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Access to the fields of a save by path, like "vars.Weather.ForcedWeatherType" or
+// "scripts.RunningScripts[name=main].Locals[3]". Field names are matched without regard to
+// case, and fields inside the unnamed groups of a block can be named without the group
+// (so "scripts.RunningScripts" is "scripts.Running.RunningScripts").
+
+// One step of a path: either a field name, or the text between a pair of brackets.
+type pathStep struct {
+	text      string
+	isBracket bool
+}
+
+// A field that holds a single value, along with its full path.
+type PathValue struct {
+	Path  string
+	Value string
+}
+
+func splitPath(path string) ([]pathStep, error) {
+	steps := []pathStep{}
+	remaining := path
+
+	for remaining != "" {
+		if remaining[0] == '[' {
+			end := strings.IndexByte(remaining, ']')
+
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in '%s'", path)
+			}
+
+			steps = append(steps, pathStep{text: remaining[1:end], isBracket: true})
+			remaining = remaining[end+1:]
+
+			if strings.HasPrefix(remaining, ".") {
+				remaining = remaining[1:]
+			}
+
+			continue
+		}
+
+		end := strings.IndexAny(remaining, ".[")
+
+		if end < 0 {
+			end = len(remaining)
+		}
+
+		if end == 0 {
+			return nil, fmt.Errorf("empty field name in '%s'", path)
+		}
+
+		steps = append(steps, pathStep{text: remaining[:end]})
+		remaining = remaining[end:]
+
+		if strings.HasPrefix(remaining, ".") {
+			remaining = remaining[1:]
+
+			if remaining == "" {
+				return nil, fmt.Errorf("empty field name in '%s'", path)
+			}
+		}
+	}
+
+	if len(steps) == 0 {
+		return nil, errors.New("the path is empty")
+	}
+
+	return steps, nil
+}
+
+// Finds the exported field called `name` in a struct. If the struct has no such field, the
+// unnamed structs inside it (which only group fields together) are searched.
+func findField(value reflect.Value, name string) (reflect.Value, error) {
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+		if field := valueType.Field(i); field.PkgPath == "" && strings.EqualFold(field.Name, name) {
+			return value.Field(i), nil
+		}
+	}
+
+	matches := []reflect.Value{}
+
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+
+		if field.PkgPath != "" || field.Type.Kind() != reflect.Struct || field.Type.Name() != "" {
+			continue
+		}
+
+		if found, err := findField(value.Field(i), name); err == nil {
+			matches = append(matches, found)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return reflect.Value{}, fmt.Errorf("there is no field called '%s'", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return reflect.Value{}, fmt.Errorf("more than one group has a field called '%s'", name)
+	}
+}
+
+// Picks an element of an array or slice, either by index or with a `<field>=<value>`
+// selector that must match exactly one element.
+func selectElement(value reflect.Value, selector string) (reflect.Value, error) {
+	equalsIndex := strings.IndexByte(selector, '=')
+
+	if equalsIndex < 0 {
+		index, err := strconv.Atoi(selector)
+
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("'%s' is not an index or a '<field>=<value>' selector", selector)
+		}
+
+		if index < 0 || index >= value.Len() {
+			return reflect.Value{}, fmt.Errorf("index %d is out of range (there are %d elements)", index, value.Len())
+		}
+
+		return value.Index(index), nil
+	}
+
+	if value.Type().Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("'%s' can only select elements with fields", selector)
+	}
+
+	fieldName, wanted := selector[:equalsIndex], selector[equalsIndex+1:]
+	matches := []int{}
+
+	for i := 0; i < value.Len(); i++ {
+		field, err := findField(value.Index(i), fieldName)
+
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		if text, ok := formatScalar(field); ok && strings.EqualFold(text, wanted) {
+			matches = append(matches, i)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return reflect.Value{}, fmt.Errorf("no element has %s '%s'", fieldName, wanted)
+	case 1:
+		return value.Index(matches[0]), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("%d elements have %s '%s' (use an index instead)", len(matches), fieldName, wanted)
+	}
+}
+
+func (save *File) resolvePath(path string) (reflect.Value, error) {
+	steps, err := splitPath(path)
+
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	value := reflect.ValueOf(save).Elem()
+
+	for _, step := range steps {
+		switch kind := value.Kind(); {
+		case step.isBracket && (kind == reflect.Array || kind == reflect.Slice):
+			value, err = selectElement(value, step.text)
+		case step.isBracket:
+			err = fmt.Errorf("'[%s]' used on something that isn't a list", step.text)
+		case kind == reflect.Struct:
+			value, err = findField(value, step.text)
+		default:
+			err = fmt.Errorf("'%s' used on something that has no fields", step.text)
+		}
+
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("bad path '%s': %v", path, err)
+		}
+	}
+
+	return value, nil
+}
+
+// Returns the text form of a single value, or false if the value isn't a single value.
+func formatScalar(value reflect.Value) (string, bool) {
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), true
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return strconv.FormatInt(value.Int(), 10), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return strconv.FormatUint(value.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits()), true
+	case reflect.String:
+		return value.String(), true
+	}
+
+	return "", false
+}
+
+func collectPathValues(value reflect.Value, path string, values []PathValue) []PathValue {
+	if text, ok := formatScalar(value); ok {
+		return append(values, PathValue{path, text})
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if field := value.Type().Field(i); field.PkgPath == "" {
+				values = collectPathValues(value.Field(i), path+"."+field.Name, values)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		// Buffers like the mission code are far too long to list byte by byte.
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
+			return append(values, PathValue{path, fmt.Sprintf("<%d bytes>", value.Len())})
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			values = collectPathValues(value.Index(i), fmt.Sprintf("%s[%d]", path, i), values)
+		}
+	}
+
+	return values
+}
+
+// Returns the value at `path`, which may be a single value or a whole structure.
+func (save *File) Get(path string) (interface{}, error) {
+	value, err := save.resolvePath(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return value.Interface(), nil
+}
+
+// Returns every single value at or under `path`, with the path to each one.
+func (save *File) GetValues(path string) ([]PathValue, error) {
+	value, err := save.resolvePath(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collectPathValues(value, path, []PathValue{}), nil
+}
+
+// Parses `str` as a value of the same type as `value`. Unsigned fields also take negative
+// numbers, which are stored as they would be in a script variable.
+func parseScalar(value reflect.Value, str string) (reflect.Value, error) {
+	parsed := reflect.New(value.Type()).Elem()
+
+	switch kind := value.Kind(); kind {
+	case reflect.Bool:
+		boolean, err := strconv.ParseBool(str)
+
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("'%s' is not true or false", str)
+		}
+
+		parsed.SetBool(boolean)
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		integer, err := ParseInteger(str, value.Type().Bits())
+
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("'%s' is not a %d-bit integer", str, value.Type().Bits())
+		}
+
+		parsed.SetInt(integer)
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		bits := value.Type().Bits()
+		unsigned, err := ParseUnsigned(str, bits)
+
+		if err != nil {
+			signed, signedErr := ParseInteger(str, bits)
+
+			if signedErr != nil {
+				return reflect.Value{}, fmt.Errorf("'%s' is not a %d-bit integer", str, bits)
+			}
+
+			unsigned = uint64(signed)
+		}
+
+		parsed.SetUint(unsigned)
+
+	case reflect.Float32, reflect.Float64:
+		floating, err := strconv.ParseFloat(str, value.Type().Bits())
+
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("'%s' is not a number", str)
+		}
+
+		parsed.SetFloat(floating)
+
+	case reflect.String:
+		parsed.SetString(str)
+
+	default:
+		return reflect.Value{}, errors.New("only single values can be set; set the fields inside it one at a time")
+	}
+
+	return parsed, nil
+}
+
+// Sets the single value at `path` from its text form. The value must fit the field's type,
+// and the sizes that the rest of the save depends on can't be changed.
+func (save *File) Set(path string, str string) error {
+	value, err := save.resolvePath(path)
+
+	if err != nil {
+		return err
+	}
+
+	if steps, _ := splitPath(path); strings.EqualFold(steps[0].text, "platform") {
+		return fmt.Errorf("can't set '%s': the platform is detected from the save", path)
+	}
+
+	parsed, err := parseScalar(value, str)
+
+	if err != nil {
+		return fmt.Errorf("can't set '%s': %v", path, err)
+	}
+
+	linksWereValid := save.Scripts.ValidateLinks() == nil
+	old := reflect.New(value.Type()).Elem()
+	old.Set(value)
+	value.Set(parsed)
+
+	err = save.Scripts.checkShape(&save.Platform)
+
+	if count := save.Scripts.Values.RunningScriptCount; err == nil && int(count) != len(save.Scripts.Running.RunningScripts) {
+		err = fmt.Errorf("there are %d running scripts, not %d", len(save.Scripts.Running.RunningScripts), count)
+	}
+
+	// Changing a script's index or links could put two scripts in the same slot, or break
+	//  the list. Saves whose list was already broken can still be changed, since the change
+	//  may be what fixes it.
+	if linksErr := save.Scripts.ValidateLinks(); err == nil && linksErr != nil && linksWereValid {
+		err = linksErr
+	}
+
+	if err != nil {
+		value.Set(old)
+		return fmt.Errorf("can't set '%s': %v", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package save
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Returns the indices of the running scripts called `name`. Names are compared without
+// case, as they are in the game.
+func (block *scriptBlock) FindScripts(name string) []int {
+	indices := []int{}
+
+	for i := range block.Running.RunningScripts {
+		if strings.EqualFold(block.Running.RunningScripts[i].Name, name) {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
+// Describes an offset in script space, saying which part of script space it is in.
+func (block *scriptBlock) DescribeOffset(offset uint32) string {
+	if missionOffset, ok := MissionOffset(offset); ok {
+		return fmt.Sprintf("mission+%d", missionOffset)
+	}
+
+	// Code embedded in global space is inside main.scm too, but it's more useful to know
+	//  that it's in global space.
+	if offset < block.GlobalStorage.GlobalSpaceSize {
+		return fmt.Sprintf("globals+%d", offset)
+	}
+
+	if offset < block.Values.MainScmSize {
+		return fmt.Sprintf("main+%d", offset)
+	}
+
+	return fmt.Sprintf("unknown+%d", offset)
+}
+
+func (theScript *script) IsMissionScript() bool {
+	return theScript.Index&missionScriptFlag != 0
+}
+
+// Returns the script's locals. Mission scripts use the mission locals instead of their own.
+func (theScript *script) ActiveLocals() []uint32 {
+	if theScript.IsMissionScript() {
+		return theScript.Mission.Locals
+	}
+
+	return theScript.Locals
+}
+
+func (theScript *script) SetLocal(index int, value uint32) error {
+	locals := theScript.ActiveLocals()
+
+	if index < 0 || index >= len(locals) {
+		return fmt.Errorf("local %d is out of range (the script has %d locals)", index, len(locals))
+	}
+
+	locals[index] = value
+	return nil
+}
+
+// Sets TIMERA (0) or TIMERB (1).
+func (theScript *script) SetTimer(index int, value uint32) error {
+	if index < 0 || index >= len(theScript.Timers) {
+		return fmt.Errorf("timer %d does not exist", index)
+	}
+
+	theScript.Timers[index] = value
+	return nil
+}
+
+// Returns the offsets on the script's return stack, with the most recent last.
+func (theScript *script) ReturnStack() []uint32 {
+	count := int(theScript.Execution.ReturnStackIndex)
+
+	if count > len(theScript.Info.RelativeReturnStack) {
+		count = len(theScript.Info.RelativeReturnStack)
+	}
+
+	return theScript.Info.RelativeReturnStack[:count]
+}
+
+// Moves the script to `offset` in script space, clearing the return stack and any
+// condition that was being evaluated.
+func (theScript *script) Jump(offset uint32) {
+	theScript.Info.RelativeInstructionPointer = offset
+	theScript.Info.RelativeReturnStack = [8]uint32{}
+	theScript.Execution.ReturnStackIndex = 0
+
+	theScript.Info.ConditionResult = false
+	theScript.Info.ConditionCount = 0
+	theScript.Info.InvertReturn = false
+}
+
+// Restarts a mission script from the start of the mission buffer. Other scripts don't
+// store where they started, so they have to be moved with Jump instead.
+func (theScript *script) RestartMission() error {
+	if !theScript.IsMissionScript() {
+		return fmt.Errorf("'%s' is not a mission script", theScript.Name)
+	}
+
+	theScript.Jump(missionBaseOffset)
+	return nil
+}
+
+// Returns the names of the script's flags that are set.
+func (theScript *script) FlagNames() []string {
+	info := &theScript.Info
+	names := []string{}
+
+	flags := []struct {
+		isSet bool
+		name  string
+	}{
+		{info.IsActive, "active"},
+		{info.IsMission, "mission"},
+		{info.UsesMissionCleanup, "cleanup"},
+		{info.IsExternal, "external"},
+		{info.OverridesTextbox, "textbox"},
+		{info.GameOverCheckActive, "gameover-check"},
+		{info.WantedOrBusted, "wasted-busted"},
+		{info.ConditionResult, "condition-result"},
+		{info.InvertReturn, "invert-return"},
+	}
+
+	for _, flag := range flags {
+		if flag.isSet {
+			names = append(names, flag.name)
+		}
+	}
+
+	return names
+}
+
+// Scripts that the game can't run without, so they can't be paused, delayed or removed.
+var requiredScripts = []string{"main"}
+
+func (theScript *script) IsRequired() bool {
+	for _, name := range requiredScripts {
+		if strings.EqualFold(theScript.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (theScript *script) checkNotRequired() error {
+	if theScript.IsRequired() {
+		return fmt.Errorf("'%s' is needed by the game", theScript.Name)
+	}
+
+	return nil
+}
+
+// Stops the script from running until it is resumed.
+func (theScript *script) Pause() error {
+	if err := theScript.checkNotRequired(); err != nil {
+		return err
+	}
+
+	theScript.Info.IsActive = false
+	return nil
+}
+
+func (theScript *script) Resume() {
+	theScript.Info.IsActive = true
+}
+
+// Stops the script from running until `milliseconds` after the save is loaded.
+func (theScript *script) Delay(vars *varBlock, milliseconds uint32) error {
+	if err := theScript.checkNotRequired(); err != nil {
+		return err
+	}
+
+	gameTime := vars.TimeMapping.TimeInMilliseconds
+
+	// The activation time would wrap around, which would start the script straight away.
+	if uint64(gameTime)+uint64(milliseconds) > math.MaxUint32 {
+		return fmt.Errorf("a delay of %d ms from the game time (%d ms) is past the latest activation time the save can hold", milliseconds, gameTime)
+	}
+
+	theScript.Info.ActivationTime = gameTime + milliseconds
+	return nil
+}
+
+// Sets the game time (in milliseconds) at which the script next runs.
+func (theScript *script) SetActivationTime(gameTime uint32) error {
+	if err := theScript.checkNotRequired(); err != nil {
+		return err
+	}
+
+	theScript.Info.ActivationTime = gameTime
+	return nil
+}
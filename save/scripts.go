@@ -1,8 +1,9 @@
 package save
 
 import (
-	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
 	"os"
 )
 
@@ -23,35 +24,40 @@ type brain struct {
 		AttachType scriptAttachType
 		GroupId    uint8
 		Status     uint32
-		Radius     float32
+		Radius     savedFloat
 	}
 
 	ScriptName string
 
-	pedOrObject struct {
+	PedOrObject struct {
 		ModelId          uint16
 		ActivationChance uint16
 		Gap              [4]padding
 	}
 }
 
+// Code-use and attractor brains store a script name instead of ped or object information.
+func (theBrain *brain) isNamed() bool {
+	attachType := theBrain.General.AttachType
+	return attachType == attachBrainForCodeUse || attachType == attachAttractorScript
+}
+
 func readBrain(file *os.File) brain {
 	theBrain := brain{}
 
 	mustRead(file, &theBrain.General)
 
-	attachType := theBrain.General.AttachType
-
-	if attachType == attachBrainForCodeUse || attachType == attachAttractorScript {
+	if theBrain.isNamed() {
 		nameBytes := make([]uint8, 8)
 		mustRead(file, &nameBytes)
 
 		theBrain.ScriptName = string(nameBytes)
+		nullTerminate(&theBrain.ScriptName)
 
 		return theBrain
 	}
 
-	mustRead(file, &theBrain.pedOrObject)
+	mustRead(file, &theBrain.PedOrObject)
 	return theBrain
 }
 
@@ -139,6 +145,9 @@ type script struct {
 		RelativeInstructionPointer uint32
 		RelativeReturnStack        [8]uint32
 	}
+
+	// The number of locals used by the script's code. Only known for embedded scripts.
+	localCount uint32
 }
 
 func readScript(platform *GamePlatform, file *os.File) script {
@@ -149,11 +158,11 @@ func readScript(platform *GamePlatform, file *os.File) script {
 		mustRead(file, &theScript.StreamedScriptIndex)
 	}
 
-	if theScript.Index&0x8000 != 0 {
-		theScript.Mission.MissionCode = make([]uint8, 69000)
+	if theScript.Index&missionScriptFlag != 0 {
+		theScript.Mission.MissionCode = make([]uint8, missionCodeSize)
 		mustRead(file, &theScript.Mission.MissionCode)
 
-		theScript.Mission.Locals = make([]uint32, 1024)
+		theScript.Mission.Locals = make([]uint32, MissionLocalCount)
 		mustRead(file, &theScript.Mission.Locals)
 	}
 
@@ -219,6 +228,12 @@ type scriptBlock struct {
 		RunningScripts []script
 	}
 
+	// Where the running scripts were in memory. Worked out from the scripts' links.
+	pool scriptPool
+
+	// Whether the links may be rebuilt even if the ones in the save are wrong.
+	relinkCorrupt bool
+
 	// There is more to the block, but we don't need any of it.
 }
 
@@ -250,61 +265,176 @@ func (block *scriptBlock) ExpandGlobalSpace(variableCount int) {
 	block.GlobalStorage.Globals[1] = (block.GlobalStorage.Globals[1] & 0xff000000) | (block.GlobalStorage.GlobalSpaceSize >> 8)
 }
 
-func (block *scriptBlock) AddScript(platform *GamePlatform, vars *varBlock, name string, contents []byte, position uint32) {
-	for i := 0; i < len(contents); i += 4 {
-		availableCount := len(contents) - i
+// Values used to start an embedded script. These work in the same way as the arguments
+// passed to `start_new_script`, so the same compiled script can be embedded several times
+// with different configurations.
+type ScriptOptions struct {
+	// Initial values for the script's local variables, keyed by local index. Use IntValue
+	//  and FloatValue to produce the raw values.
+	Locals map[int]uint32
+
+	// Initial values for TIMERA and TIMERB.
+	Timers [2]uint32
+
+	// Thread flags. These are copied straight into the script's Info structure.
+	IsMission           bool
+	UsesMissionCleanup  bool
+	IsExternal          bool
+	GameOverCheckActive bool
+	WantedOrBusted      bool
+
+	// How long (in milliseconds) after the save loads the script should wait before starting.
+	ActivationDelay uint32
+
+	// Offset of the instruction to start at, relative to the beginning of the script.
+	EntryOffset uint32
+
+	// The number of locals that the script's code uses, if known. It's worked out from the
+	// code when the script is embedded, so it isn't kept in JSON (such as patches).
+	LocalCount int `json:"-"`
+}
 
-		if availableCount > 4 {
-			availableCount = 4
+// Checks the options against the script's code, given the number of locals the script can use
+// and the save's variables (for the game time that the delay is added to).
+func (options *ScriptOptions) Validate(contents []byte, localLimit int, vars *varBlock) error {
+	for index := range options.Locals {
+		if index < 0 || index >= localLimit {
+			return fmt.Errorf("local %d is out of range (the script has %d locals)", index, localLimit)
 		}
+	}
+
+	if options.LocalCount > localLimit {
+		return fmt.Errorf("the script uses %d locals, but only %d are available", options.LocalCount, localLimit)
+	}
+
+	if int(options.EntryOffset) >= len(contents) {
+		return fmt.Errorf("entry offset %d is outside the script (which is %d bytes long)", options.EntryOffset, len(contents))
+	}
 
-		variableBytes := make([]byte, 4)
+	// The activation time is stored in 32 bits, so a delay that takes it past that would
+	//  wrap around and start the script straight away.
+	if gameTime := vars.TimeMapping.TimeInMilliseconds; uint64(gameTime)+uint64(options.ActivationDelay) > math.MaxUint32 {
+		return fmt.Errorf("a delay of %d ms from the game time (%d ms) is past the latest activation time the save can hold", options.ActivationDelay, gameTime)
+	}
 
-		// Only copy in as many bytes as we have available. Any bytes we don't fill
-		//  will be zero, so the result is the same as if the source was padded to
-		//  a multiple of 4.
-		copy(variableBytes, contents[i:i+availableCount])
+	return nil
+}
 
-		globalValue := binary.LittleEndian.Uint32(variableBytes)
+// Creates a running script for embedded code that starts at `position` in script space.
+// The caller is responsible for setting the script's locals.
+func (block *scriptBlock) newEmbeddedScript(platform *GamePlatform, vars *varBlock, name string, position uint32, options *ScriptOptions) (script, error) {
+	freeSlots := block.FreeScriptSlots()
 
-		// Divide by 4 to obtain the index of the variable which will hold these bytes.
-		globalIndex := (int(position) + i) / 4
-		block.GlobalStorage.Globals[globalIndex] = globalValue
+	if len(freeSlots) == 0 {
+		return script{}, fmt.Errorf("there are no free script slots (the game allows %d running scripts)", scriptPoolSize)
 	}
 
 	theScript := script{
-		Index:               0,
+		Index:               freeSlots[0],
 		StreamedScriptIndex: -1,
-		Name:                name,
 		Locals:              make([]uint32, platform.MaxLocals()),
+		Timers:              options.Timers,
+		localCount:          uint32(options.LocalCount),
+	}
+
+	if err := theScript.SetName(name, RejectLong); err != nil {
+		return theScript, err
 	}
 
 	theScript.Info.IsActive = true
 	theScript.Info.AttachType = attachNotInUse
-	theScript.Info.RelativeInstructionPointer = position
+	theScript.Info.RelativeInstructionPointer = position + options.EntryOffset
+
+	theScript.Info.IsMission = options.IsMission
+	theScript.Info.UsesMissionCleanup = options.UsesMissionCleanup
+	theScript.Info.IsExternal = options.IsExternal
+	theScript.Info.GameOverCheckActive = options.GameOverCheckActive
+	theScript.Info.WantedOrBusted = options.WantedOrBusted
+
+	// Set the activation time relative to the game time. With no delay, the script
+	//  launches straight away.
+	theScript.Info.ActivationTime = vars.TimeMapping.TimeInMilliseconds + options.ActivationDelay
+
+	return theScript, nil
+}
+
+func (block *scriptBlock) AddScript(platform *GamePlatform, vars *varBlock, name string, contents []byte, position uint32, options *ScriptOptions) error {
+	if options == nil {
+		options = &ScriptOptions{}
+	}
 
-	// Set the activation time to the game time so that the script launches
-	//  straight away.
-	theScript.Info.ActivationTime = vars.TimeMapping.TimeInMilliseconds
+	if err := options.Validate(contents, platform.MaxLocals(), vars); err != nil {
+		return err
+	}
+
+	if err := block.checkGlobalRegion(position, uint32(len(contents))); err != nil {
+		return err
+	}
+
+	if err := block.checkRelinkable(); err != nil {
+		return err
+	}
+
+	theScript, err := block.newEmbeddedScript(platform, vars, name, position, options)
+
+	if err != nil {
+		return err
+	}
 
-	// Add the script to the end of the array.
+	block.packGlobals(contents, position)
+
+	for index, value := range options.Locals {
+		theScript.Locals[index] = value
+	}
+
+	// Add the script to the end of the list.
 	block.Running.RunningScripts = append(block.Running.RunningScripts, theScript)
-	block.Values.RunningScriptCount++
+	block.relinkScripts()
+
+	return nil
 }
 
-func WriteScriptBlock(platform *GamePlatform, file io.Writer, block *scriptBlock) {
+func WriteScriptBlock(platform *GamePlatform, file io.Writer, block *scriptBlock) error {
+	// Encode all of the names up front so that we don't write half a block if one is bad.
+	brainNames := make([][scriptNameSize]byte, len(block.Brains))
+
+	for i, theBrain := range block.Brains {
+		if !theBrain.isNamed() {
+			continue
+		}
+
+		encoded, err := encodeScriptName(theBrain.ScriptName, RejectLong)
+
+		if err != nil {
+			return fmt.Errorf("bad name for brain %d: %v", i, err)
+		}
+
+		brainNames[i] = encoded
+	}
+
+	scriptNames := make([][scriptNameSize]byte, len(block.Running.RunningScripts))
+
+	for i, theScript := range block.Running.RunningScripts {
+		encoded, err := encodeScriptName(theScript.Name, RejectLong)
+
+		if err != nil {
+			return fmt.Errorf("bad name for running script %d: %v", i, err)
+		}
+
+		scriptNames[i] = encoded
+	}
+
 	mustWrite(file, block.blockIdentifier)
 	mustWrite(file, block.GlobalStorage.GlobalSpaceSize)
 	mustWrite(file, block.GlobalStorage.Globals)
 
-	for _, theBrain := range block.Brains {
+	for i, theBrain := range block.Brains {
 		mustWrite(file, theBrain.General)
 
-		if theBrain.General.AttachType == attachBrainForCodeUse || theBrain.General.AttachType == attachAttractorScript {
-			mustWrite(file, ([]byte)(theBrain.ScriptName))
-			mustWrite(file, make([]byte, 8-len(theBrain.ScriptName)))
+		if theBrain.isNamed() {
+			mustWrite(file, brainNames[i])
 		} else {
-			mustWrite(file, theBrain.pedOrObject)
+			mustWrite(file, theBrain.PedOrObject)
 		}
 	}
 
@@ -316,26 +446,27 @@ func WriteScriptBlock(platform *GamePlatform, file io.Writer, block *scriptBlock
 		mustWrite(file, block.SaveGameStateType)
 	}
 
-	for _, theScript := range block.Running.RunningScripts {
+	for i, theScript := range block.Running.RunningScripts {
 		mustWrite(file, theScript.Index)
 
 		if platform.IsMobile {
 			mustWrite(file, theScript.StreamedScriptIndex)
 		}
 
-		if theScript.Index&0x8000 != 0 {
+		if theScript.Index&missionScriptFlag != 0 {
 			mustWrite(file, theScript.Mission.MissionCode)
 			mustWrite(file, theScript.Mission.Locals)
 		}
 
 		mustWrite(file, theScript.Link)
-		mustWrite(file, ([]byte)(theScript.Name))
-		mustWrite(file, make([]byte, 8-len(theScript.Name)))
+		mustWrite(file, scriptNames[i])
 		mustWrite(file, theScript.Execution)
 		mustWrite(file, theScript.Locals)
 		mustWrite(file, theScript.Timers)
 		mustWrite(file, theScript.Info)
 	}
+
+	return nil
 }
 
 func ReadScriptBlock(platform *GamePlatform, file *os.File) scriptBlock {
@@ -345,6 +476,11 @@ func ReadScriptBlock(platform *GamePlatform, file *os.File) scriptBlock {
 
 	mustRead(file, &block.GlobalStorage.GlobalSpaceSize)
 
+	// Don't try to make space for more globals than the file could hold.
+	if fileInfo, err := file.Stat(); err == nil && int64(block.GlobalStorage.GlobalSpaceSize) > fileInfo.Size() {
+		panic(fmt.Errorf("global space is %d bytes, which is more than the whole file", block.GlobalStorage.GlobalSpaceSize))
+	}
+
 	// Size is in bytes, so divide by 4 to find the number of uint32s.
 	block.GlobalStorage.Globals = make([]uint32, block.GlobalStorage.GlobalSpaceSize/4)
 	mustRead(file, &block.GlobalStorage.Globals)
@@ -370,5 +506,7 @@ func ReadScriptBlock(platform *GamePlatform, file *os.File) scriptBlock {
 		block.Running.RunningScripts[i] = readScript(platform, file)
 	}
 
+	block.inferPool(platform)
+
 	return block
 }
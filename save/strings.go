@@ -0,0 +1,166 @@
+package save
+
+import (
+	"fmt"
+	"unicode/utf16"
+)
+
+// Decides what happens to a string that is too long for the fixed-width field it is
+// being stored in.
+type LengthPolicy int
+
+const (
+	// Return an error if the string is too long.
+	RejectLong LengthPolicy = iota
+
+	// Cut the string down to the longest prefix that fits.
+	TruncateLong
+)
+
+const (
+	// Script and brain names are stored in 8 bytes. Names that fill all 8 bytes have no
+	//  null terminator, which the game handles fine.
+	scriptNameSize = 8
+
+	// The last mission passed is stored as 100 characters (which may be either one
+	//  or two bytes each). As with names, text that fills the field has no terminator.
+	missionTextSize = 100
+)
+
+// Encodes a script name as null-padded ASCII.
+func encodeScriptName(name string, policy LengthPolicy) ([scriptNameSize]byte, error) {
+	encoded := [scriptNameSize]byte{}
+
+	for i, character := range []byte(name) {
+		if i >= scriptNameSize {
+			if policy == TruncateLong {
+				break
+			}
+
+			return encoded, fmt.Errorf("script name '%s' is longer than %d characters", name, scriptNameSize)
+		}
+
+		if character == 0 || character > 0x7f {
+			return encoded, fmt.Errorf("script name '%s' contains a non-ASCII character", name)
+		}
+
+		encoded[i] = character
+	}
+
+	return encoded, nil
+}
+
+// Encodes text as null-padded UTF-16. Characters are never split, so truncated text may be
+// shorter than the maximum length if the last character needs a surrogate pair.
+func encodeWideText(text string, size int, policy LengthPolicy) ([]uint16, error) {
+	encoded := make([]uint16, 0, size)
+
+	for _, character := range text {
+		units := utf16.Encode([]rune{character})
+
+		if len(encoded)+len(units) > size {
+			if policy == TruncateLong {
+				break
+			}
+
+			return nil, fmt.Errorf("'%s' is longer than %d UTF-16 characters", text, size)
+		}
+
+		encoded = append(encoded, units...)
+	}
+
+	return append(encoded, make([]uint16, size-len(encoded))...), nil
+}
+
+// Encodes text as null-padded single-byte characters. Only characters that fit in a byte
+// (Latin-1) can be stored.
+func encodeNarrowText(text string, size int, policy LengthPolicy) ([]uint8, error) {
+	encoded := make([]uint8, 0, size)
+
+	for _, character := range text {
+		if len(encoded) >= size {
+			if policy == TruncateLong {
+				break
+			}
+
+			return nil, fmt.Errorf("'%s' is longer than %d characters", text, size)
+		}
+
+		if character == 0 || character > 0xff {
+			return nil, fmt.Errorf("'%s' contains the character '%c', which cannot be stored in a single byte", text, character)
+		}
+
+		encoded = append(encoded, uint8(character))
+	}
+
+	return append(encoded, make([]uint8, size-len(encoded))...), nil
+}
+
+// Decodes text stored as single-byte characters.
+func decodeNarrowText(characters []uint8) string {
+	runes := make([]rune, len(characters))
+
+	for i, character := range characters {
+		runes[i] = rune(character)
+	}
+
+	return string(runes)
+}
+
+// Converts an encoded script name back into a string.
+func storedScriptName(encoded [scriptNameSize]byte) string {
+	name := string(encoded[:])
+	nullTerminate(&name)
+
+	return name
+}
+
+// Sets the name of the script, which must be ASCII.
+func (theScript *script) SetName(name string, policy LengthPolicy) error {
+	encoded, err := encodeScriptName(name, policy)
+
+	if err != nil {
+		return err
+	}
+
+	theScript.Name = storedScriptName(encoded)
+	return nil
+}
+
+// Sets the name of the script that the brain launches. Only used for code-use and
+// attractor brains.
+func (theBrain *brain) SetScriptName(name string, policy LengthPolicy) error {
+	encoded, err := encodeScriptName(name, policy)
+
+	if err != nil {
+		return err
+	}
+
+	theBrain.ScriptName = storedScriptName(encoded)
+	return nil
+}
+
+// Sets the last mission passed text, which the game shows as the save's title. The text is
+// stored as UTF-16 or single bytes depending on the platform.
+func (block *varBlock) SetLastMissionPassed(platform *GamePlatform, text string, policy LengthPolicy) error {
+	if platform.IsWideChar {
+		encoded, err := encodeWideText(text, missionTextSize, policy)
+
+		if err != nil {
+			return err
+		}
+
+		block.Metadata.LastMissionPassed = string(utf16.Decode(encoded))
+	} else {
+		encoded, err := encodeNarrowText(text, missionTextSize, policy)
+
+		if err != nil {
+			return err
+		}
+
+		block.Metadata.LastMissionPassed = decodeNarrowText(encoded)
+	}
+
+	nullTerminate(&block.Metadata.LastMissionPassed)
+	return nil
+}
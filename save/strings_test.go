@@ -0,0 +1,98 @@
+package save
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestEncodeScriptName(t *testing.T) {
+	encoded, err := encodeScriptName("main", RejectLong)
+
+	if err != nil || storedScriptName(encoded) != "main" || encoded[4] != 0 {
+		t.Errorf("short name: got %v, %v", encoded, err)
+	}
+
+	// A name that fills the field has no terminator.
+	if encoded, err := encodeScriptName("abcdefgh", RejectLong); err != nil || storedScriptName(encoded) != "abcdefgh" {
+		t.Errorf("8-character name: got %v, %v", encoded, err)
+	}
+
+	if _, err := encodeScriptName("abcdefghi", RejectLong); err == nil {
+		t.Error("9-character name was accepted with RejectLong")
+	}
+
+	if encoded, err := encodeScriptName("abcdefghi", TruncateLong); err != nil || storedScriptName(encoded) != "abcdefgh" {
+		t.Errorf("9-character name with TruncateLong: got %v, %v", encoded, err)
+	}
+
+	for _, name := range []string{"café", "a\x00b"} {
+		if _, err := encodeScriptName(name, TruncateLong); err == nil {
+			t.Errorf("name %q was accepted", name)
+		}
+	}
+}
+
+func TestEncodeWideText(t *testing.T) {
+	encoded, err := encodeWideText("Intro", 8, RejectLong)
+
+	if err != nil || len(encoded) != 8 || string(utf16.Decode(encoded[:5])) != "Intro" || encoded[5] != 0 {
+		t.Errorf("short text: got %v, %v", encoded, err)
+	}
+
+	if encoded, err := encodeWideText("12345678", 8, RejectLong); err != nil || string(utf16.Decode(encoded)) != "12345678" {
+		t.Errorf("text at the limit: got %v, %v", encoded, err)
+	}
+
+	if _, err := encodeWideText("123456789", 8, RejectLong); err == nil {
+		t.Error("text over the limit was accepted with RejectLong")
+	}
+
+	if encoded, err := encodeWideText("123456789", 8, TruncateLong); err != nil || string(utf16.Decode(encoded)) != "12345678" {
+		t.Errorf("text over the limit with TruncateLong: got %v, %v", encoded, err)
+	}
+
+	// U+1F600 needs a surrogate pair, so it takes two of the eight units.
+	if encoded, err := encodeWideText("123456\U0001F600", 8, RejectLong); err != nil || string(utf16.Decode(encoded)) != "123456\U0001F600" {
+		t.Errorf("surrogate pair at the limit: got %v, %v", encoded, err)
+	}
+
+	if _, err := encodeWideText("1234567\U0001F600", 8, RejectLong); err == nil {
+		t.Error("surrogate pair over the limit was accepted with RejectLong")
+	}
+
+	// The pair isn't split, so the truncated text is one unit short.
+	encoded, err = encodeWideText("1234567\U0001F600", 8, TruncateLong)
+
+	if err != nil || string(utf16.Decode(encoded[:7])) != "1234567" || encoded[7] != 0 {
+		t.Errorf("surrogate pair with TruncateLong: got %v, %v", encoded, err)
+	}
+}
+
+func TestEncodeNarrowText(t *testing.T) {
+	encoded, err := encodeNarrowText("Café", 8, RejectLong)
+
+	if err != nil || len(encoded) != 8 || decodeNarrowText(encoded[:4]) != "Café" || encoded[4] != 0 {
+		t.Errorf("Latin-1 text: got %v, %v", encoded, err)
+	}
+
+	limit := strings.Repeat("x", missionTextSize)
+
+	if encoded, err := encodeNarrowText(limit, missionTextSize, RejectLong); err != nil || decodeNarrowText(encoded) != limit {
+		t.Errorf("text at the limit: got %v, %v", encoded, err)
+	}
+
+	if _, err := encodeNarrowText(limit+"y", missionTextSize, RejectLong); err == nil {
+		t.Error("text over the limit was accepted with RejectLong")
+	}
+
+	if encoded, err := encodeNarrowText(limit+"y", missionTextSize, TruncateLong); err != nil || decodeNarrowText(encoded) != limit {
+		t.Errorf("text over the limit with TruncateLong: got %v, %v", encoded, err)
+	}
+
+	for _, text := range []string{"Ā", "\U0001F600", "a\x00b"} {
+		if _, err := encodeNarrowText(text, 8, TruncateLong); err == nil {
+			t.Errorf("text %q was accepted", text)
+		}
+	}
+}
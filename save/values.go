@@ -0,0 +1,77 @@
+package save
+
+import "fmt"
+
+// The summary values at the end of the script block's fixed part describe main.scm and the
+// running scripts. Most of them come from main.scm and can only be checked against the rest
+// of the block, but the running script count and the highest local can be recomputed.
+
+// Returns the number of locals that the highest-using embedded script needs, or zero if no
+// embedded script's usage is known.
+func (block *scriptBlock) embeddedLocalCount() uint32 {
+	var highest uint32 = 0
+
+	for i := range block.Running.RunningScripts {
+		if count := block.Running.RunningScripts[i].localCount; count > highest {
+			highest = count
+		}
+	}
+
+	return highest
+}
+
+// Checks the script block's summary values against the rest of the block, returning a
+// description of each problem found.
+func (block *scriptBlock) CheckValues() []string {
+	problems := []string{}
+	values := &block.Values
+
+	if int(values.RunningScriptCount) != len(block.Running.RunningScripts) {
+		problems = append(problems, fmt.Sprintf("running script count is %d, but there are %d running scripts",
+			values.RunningScriptCount, len(block.Running.RunningScripts)))
+	}
+
+	if localCount := block.embeddedLocalCount(); values.HighestLocal < localCount {
+		problems = append(problems, fmt.Sprintf("highest local is %d, but an embedded script uses %d locals",
+			values.HighestLocal, localCount))
+	}
+
+	// Global storage is the start of main.scm, so it can't be any bigger than main.scm is.
+	if block.GlobalStorage.GlobalSpaceSize > values.MainScmSize {
+		problems = append(problems, fmt.Sprintf("global space is %d bytes, but main.scm is only %d bytes",
+			block.GlobalStorage.GlobalSpaceSize, values.MainScmSize))
+	}
+
+	for i := range block.Running.RunningScripts {
+		theScript := &block.Running.RunningScripts[i]
+
+		if theScript.Index&missionScriptFlag != 0 {
+			if _, ok := MissionOffset(theScript.Info.RelativeInstructionPointer); !ok {
+				problems = append(problems, fmt.Sprintf("mission script '%s' is at offset %d, which is outside the mission buffer",
+					theScript.Name, theScript.Info.RelativeInstructionPointer))
+			}
+
+			continue
+		}
+
+		if theScript.Info.IsExternal {
+			continue
+		}
+
+		if pointer := theScript.Info.RelativeInstructionPointer; pointer >= values.MainScmSize {
+			problems = append(problems, fmt.Sprintf("script '%s' is at offset %d, which is outside main.scm (%d bytes)",
+				theScript.Name, pointer, values.MainScmSize))
+		}
+	}
+
+	return problems
+}
+
+// Recomputes the summary values that can be worked out from the block's contents.
+func (block *scriptBlock) RecomputeValues() {
+	block.Values.RunningScriptCount = uint32(len(block.Running.RunningScripts))
+
+	if localCount := block.embeddedLocalCount(); block.Values.HighestLocal < localCount {
+		block.Values.HighestLocal = localCount
+	}
+}
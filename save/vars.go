@@ -1,6 +1,7 @@
 package save
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"unicode/utf16"
@@ -64,9 +65,9 @@ type varBlock struct {
 	// Information for mapping between real time and game time.
 	TimeMapping struct {
 		TimeInMilliseconds uint32
-		TimeScale          float32
-		TimeStep           float32
-		TimeStepNonClipped float32
+		TimeScale          savedFloat
+		TimeStep           savedFloat
+		TimeStepNonClipped savedFloat
 		FrameCounter       uint32
 	}
 
@@ -78,9 +79,9 @@ type varBlock struct {
 		// Pad to 8 bytes; we're at 6 currently.
 		Gap [2]padding
 
-		InterpolationValue float32
+		InterpolationValue savedFloat
 		WeatherTypeInList  uint32
-		RainHeaviness      float32
+		RainHeaviness      savedFloat
 	}
 
 	Camera struct {
@@ -97,7 +98,7 @@ type varBlock struct {
 			Color              uint32
 			Enabled            bool
 			Gap                boolPadding
-			InterpolationValue float32
+			InterpolationValue savedFloat
 			WeatherType        uint32
 		}
 
@@ -156,27 +157,25 @@ type varBlock struct {
 	MobileUnknown [4]uint8
 }
 
-func WriteVarBlock(platform *GamePlatform, file io.Writer, block *varBlock) {
-	mustWrite(file, block.blockIdentifier)
-	mustWrite(file, block.Metadata.VersionNumber)
+func WriteVarBlock(platform *GamePlatform, file io.Writer, block *varBlock) error {
+	// Encode the text before writing anything so that we don't write half a block.
+	var encodedMission interface{}
+	var err error
 
 	if platform.IsWideChar {
-		// UTF-16
-		missionRunes := []rune(block.Metadata.LastMissionPassed)
-		encoded := utf16.Encode(missionRunes)
-
-		mustWrite(file, encoded)
-
-		// Pad to 100 characters long.
-		mustWrite(file, make([]uint16, 100-len(encoded)))
+		encodedMission, err = encodeWideText(block.Metadata.LastMissionPassed, missionTextSize, RejectLong)
 	} else {
-		// UTF-8
-		encoded := []byte(block.Metadata.LastMissionPassed)
+		encodedMission, err = encodeNarrowText(block.Metadata.LastMissionPassed, missionTextSize, RejectLong)
+	}
 
-		mustWrite(file, encoded)
-		mustWrite(file, make([]uint8, 100-len(encoded)))
+	if err != nil {
+		return fmt.Errorf("bad last mission passed text: %v", err)
 	}
 
+	mustWrite(file, block.blockIdentifier)
+	mustWrite(file, block.Metadata.VersionNumber)
+	mustWrite(file, encodedMission)
+
 	mustWrite(file, block.Metadata.MissionPackGame)
 	mustWrite(file, block.Metadata.Gap)
 
@@ -208,6 +207,8 @@ func WriteVarBlock(platform *GamePlatform, file io.Writer, block *varBlock) {
 	if platform.IsMobile {
 		mustWrite(file, block.MobileUnknown)
 	}
+
+	return nil
 }
 
 func ReadVarBlock(platform *GamePlatform, file *os.File) varBlock {
@@ -227,7 +228,7 @@ func ReadVarBlock(platform *GamePlatform, file *os.File) varBlock {
 		characters := make([]uint8, 100)
 		mustRead(file, &characters)
 
-		block.Metadata.LastMissionPassed = string(characters)
+		block.Metadata.LastMissionPassed = decodeNarrowText(characters)
 	}
 
 	nullTerminate(&block.Metadata.LastMissionPassed)
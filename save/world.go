@@ -0,0 +1,189 @@
+package save
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setters for the parts of the var block that describe the state of the world. Flags that
+// can be set to anything (like the cheats) can be changed directly.
+
+const (
+	// The number of weather types that the game has.
+	weatherTypeCount = 23
+
+	// The forced weather type when no weather is forced.
+	noForcedWeather = 0xffff
+
+	maxWantedLevel = 6
+
+	// The timecycle has a colour for each of 8 times of day, and the last two weather types
+	//  (EXTRACOLOURS_1 and EXTRACOLOURS_2) hold the extra colours, so there are 16 of them.
+	timeCycleHourCount     = 8
+	firstExtraColorWeather = 21
+	extraColorCount        = (weatherTypeCount - firstExtraColorWeather) * timeCycleHourCount
+)
+
+// The chaos level that goes with each maximum wanted level. These are the values that
+// `CWanted::SetMaximumWantedLevel` uses (see Wanted.cpp in the re3 and gta-reversed
+// projects, which reverse engineer the games).
+var wantedLevelChaos = [maxWantedLevel + 1]uint32{0, 115, 365, 875, 1800, 3500, 6900}
+
+// Names of the days of the week, in the order the game numbers them (from 1).
+var weekdayNames = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+// Sets the time of day. The next minute starts counting from when the save is loaded.
+func (block *varBlock) SetTime(hour int, minute int) error {
+	if hour < 0 || hour > 23 {
+		return fmt.Errorf("hour %d is not between 0 and 23", hour)
+	}
+
+	if minute < 0 || minute > 59 {
+		return fmt.Errorf("minute %d is not between 0 and 59", minute)
+	}
+
+	block.Clock.GameClock.Hour = uint8(hour)
+	block.Clock.GameClock.Minute = uint8(minute)
+	block.Clock.LastClockTick = block.TimeMapping.TimeInMilliseconds
+
+	return nil
+}
+
+// Sets the day of the week, from 1 (Sunday) to 7 (Saturday).
+func (block *varBlock) SetWeekday(weekday int) error {
+	if weekday < 1 || weekday > len(weekdayNames) {
+		return fmt.Errorf("weekday %d is not between 1 (Sunday) and 7 (Saturday)", weekday)
+	}
+
+	block.Clock.Weekday = uint8(weekday)
+	return nil
+}
+
+// Returns the name of the day of the week, or "unknown" if the weekday isn't valid.
+func (block *varBlock) WeekdayName() string {
+	if block.Clock.Weekday < 1 || int(block.Clock.Weekday) > len(weekdayNames) {
+		return "unknown"
+	}
+
+	return weekdayNames[block.Clock.Weekday-1]
+}
+
+// Parses a day of the week given as a number (1 for Sunday to 7 for Saturday) or as a
+// name, which can be shortened to three letters.
+func ParseWeekday(str string) (int, error) {
+	if weekday, err := strconv.Atoi(str); err == nil {
+		return weekday, nil
+	}
+
+	if len(str) >= 3 {
+		for i, name := range weekdayNames {
+			if strings.HasPrefix(strings.ToLower(name), strings.ToLower(str)) {
+				return i + 1, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("'%s' is not a day of the week", str)
+}
+
+// Forces a weather type (0 to 22), or stops forcing the weather if `weather` is -1.
+func (block *varBlock) SetForcedWeather(weather int) error {
+	if weather == -1 {
+		block.Weather.ForcedWeatherType = noForcedWeather
+		return nil
+	}
+
+	if weather < 0 || weather >= weatherTypeCount {
+		return fmt.Errorf("weather type %d is not between 0 and %d (or -1 for none)", weather, weatherTypeCount-1)
+	}
+
+	block.Weather.ForcedWeatherType = uint16(weather)
+	return nil
+}
+
+// Returns the forced weather type, or -1 if the weather isn't forced.
+func (block *varBlock) ForcedWeather() int {
+	if block.Weather.ForcedWeatherType == noForcedWeather {
+		return -1
+	}
+
+	return int(block.Weather.ForcedWeatherType)
+}
+
+// Sets how heavy the rain is, from 0 (none) to 1.
+func (block *varBlock) SetRainHeaviness(heaviness float32) error {
+	if !(0 <= heaviness && heaviness <= 1) {
+		return fmt.Errorf("rain heaviness %g is not between 0 and 1", heaviness)
+	}
+
+	block.Weather.RainHeaviness = savedFloat(heaviness)
+	return nil
+}
+
+// Sets the maximum wanted level (0 to 6), along with the chaos level that goes with it.
+func (block *varBlock) SetMaxWantedLevel(level int) error {
+	if level < 0 || level > maxWantedLevel {
+		return fmt.Errorf("wanted level %d is not between 0 and %d", level, maxWantedLevel)
+	}
+
+	block.WantedLevel.Maximum = uint32(level)
+	block.WantedLevel.MaximumChaos = wantedLevelChaos[level]
+
+	return nil
+}
+
+// Turns on one of the timecycle's extra colours (0 to 15), or turns the extra colour off if
+// `color` is -1. The colour is shown straight away rather than fading in.
+func (block *varBlock) SetExtraColor(color int) error {
+	extraColor := &block.Surroundings.ExtraColor
+
+	if color == -1 {
+		extraColor.Enabled = false
+		extraColor.InterpolationValue = 0
+
+		return nil
+	}
+
+	if color < 0 || color >= extraColorCount {
+		return fmt.Errorf("extra colour %d is not between 0 and %d (or -1 to turn it off)", color, extraColorCount-1)
+	}
+
+	// The game stores the colour as a time of day in one of the extra colour weathers.
+	extraColor.Color = uint32(color % timeCycleHourCount)
+	extraColor.WeatherType = uint32(firstExtraColorWeather + color/timeCycleHourCount)
+	extraColor.Enabled = true
+	extraColor.InterpolationValue = 1
+
+	return nil
+}
+
+// Sets the real time at which the save was made, which the PC version shows in the load
+// menu. Other platforms don't store it.
+func (block *varBlock) SetSystemTime(platform *GamePlatform, when time.Time) error {
+	if !platform.IsPC {
+		return fmt.Errorf("only PC saves store the system time")
+	}
+
+	block.TimeGroup.DesktopSystemTime = systemTime{
+		Year:        uint16(when.Year()),
+		Month:       uint16(when.Month()),
+		DayOfWeek:   uint16(when.Weekday()),
+		Day:         uint16(when.Day()),
+		Hour:        uint16(when.Hour()),
+		Minute:      uint16(when.Minute()),
+		Second:      uint16(when.Second()),
+		Millisecond: uint16(when.Nanosecond() / int(time.Millisecond)),
+	}
+
+	return nil
+}
+
+// Returns the real time at which a PC save was made.
+func (block *varBlock) SystemTime() time.Time {
+	stored := &block.TimeGroup.DesktopSystemTime
+
+	return time.Date(int(stored.Year), time.Month(stored.Month), int(stored.Day), int(stored.Hour), int(stored.Minute),
+		int(stored.Second), int(stored.Millisecond)*int(time.Millisecond), time.Local)
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Finds a running script from either its name or its position in the list (as "#<index>").
+func findScript(saveFile *save.File, selector string) int {
+	scripts := &saveFile.Scripts
+
+	if strings.HasPrefix(selector, "#") {
+		index, err := strconv.Atoi(selector[1:])
+
+		if err != nil || index < 0 || index >= len(scripts.Running.RunningScripts) {
+			fmt.Printf("There is no running script at '%s'.\n", selector)
+			os.Exit(1)
+		}
+
+		return index
+	}
+
+	indices := scripts.FindScripts(selector)
+
+	if len(indices) == 0 {
+		fmt.Printf("There is no running script called '%s'.\n", selector)
+		os.Exit(1)
+	}
+
+	if len(indices) > 1 {
+		fmt.Printf("There are %d running scripts called '%s'. Use '#<index>' to pick one:", len(indices), selector)
+
+		for _, index := range indices {
+			fmt.Printf(" #%d", index)
+		}
+
+		fmt.Println()
+		os.Exit(1)
+	}
+
+	return indices[0]
+}
+
+// Formats a variable as both an integer and a float, since we can't tell which it is.
+func formatVariable(value uint32) string {
+	return fmt.Sprintf("%d (%g)", int32(value), math.Float32frombits(value))
+}
+
+func joinOrNone(strs []string) string {
+	if len(strs) == 0 {
+		return "none"
+	}
+
+	return strings.Join(strs, ", ")
+}
+
+func printLocals(locals []uint32, showAll bool) {
+	zeroCount := 0
+
+	for index, value := range locals {
+		if value == 0 && !showAll {
+			zeroCount++
+			continue
+		}
+
+		fmt.Printf("    %d@ = %s\n", index, formatVariable(value))
+	}
+
+	if zeroCount != 0 {
+		fmt.Printf("    (%d other locals are zero)\n", zeroCount)
+	}
+}
+
+func inspectScripts(saveFile *save.File, showAll bool) {
+	scripts := &saveFile.Scripts
+	gameTime := saveFile.Vars.TimeMapping.TimeInMilliseconds
+
+	fmt.Printf("%d running scripts.\n", len(scripts.Running.RunningScripts))
+
+	for i := range scripts.Running.RunningScripts {
+		theScript := &scripts.Running.RunningScripts[i]
+
+		fmt.Printf("\n#%d '%s' (slot %d)\n", i, theScript.Name, theScript.Slot())
+		fmt.Printf("  Flags: %s\n", joinOrNone(theScript.FlagNames()))
+		fmt.Printf("  Instruction pointer: %s\n", scripts.DescribeOffset(theScript.Info.RelativeInstructionPointer))
+
+		returnStack := theScript.ReturnStack()
+		descriptions := make([]string, len(returnStack))
+
+		for j, offset := range returnStack {
+			descriptions[j] = scripts.DescribeOffset(offset)
+		}
+
+		fmt.Printf("  Return stack: %s\n", joinOrNone(descriptions))
+
+		activationTime := theScript.Info.ActivationTime
+
+		if activationTime > gameTime {
+			fmt.Printf("  Activation time: %d (in %d ms)\n", activationTime, activationTime-gameTime)
+		} else {
+			fmt.Printf("  Activation time: %d\n", activationTime)
+		}
+
+		fmt.Printf("  TIMERA = %s, TIMERB = %s\n", formatVariable(theScript.Timers[0]), formatVariable(theScript.Timers[1]))
+
+		if theScript.IsMissionScript() {
+			fmt.Println("  Mission locals:")
+		} else {
+			fmt.Println("  Locals:")
+		}
+
+		printLocals(theScript.ActiveLocals(), showAll)
+	}
+}
+
+func inspectCommand(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	showAll := flags.Bool("all", false, "show every local, including those that are zero")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] scripts <save>'\n", name)
+		fmt.Printf("   or: '%s main <main.scm> [save to check]'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	switch {
+	case flags.NArg() == 2 && flags.Arg(0) == "scripts":
+		saveFile := loadSave(flags.Arg(1))
+		inspectScripts(&saveFile, *showAll)
+
+	case (flags.NArg() == 2 || flags.NArg() == 3) && flags.Arg(0) == "main":
+		mainFile, err := readMainScm(flags.Arg(1))
+
+		if err != nil {
+			fmt.Printf("Unable to read main.scm: %v\n", err)
+			os.Exit(1)
+		}
+
+		inspectMainScm(mainFile)
+
+		if flags.NArg() == 3 {
+			saveFile := loadSave(flags.Arg(2))
+			problems := mainFile.checkSave(&saveFile)
+
+			for _, problem := range problems {
+				fmt.Printf("Mismatch: %s.\n", problem)
+			}
+
+			if len(problems) == 0 {
+				fmt.Println("The save matches main.scm.")
+			}
+		}
+
+	default:
+		flags.Usage()
+		os.Exit(1)
+	}
+}
+
+func setScriptValues(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	locals := variableValues{}
+	flags.Var(locals, "local", "new value for a local variable, as `<index>=<value>` (repeatable)")
+
+	timerA := flags.Int("timera", 0, "new value for TIMERA")
+	timerB := flags.Int("timerb", 0, "new value for TIMERB")
+	offset := flags.Uint("ip", 0, "move the script to this offset in script space, clearing its return stack")
+	restart := flags.Bool("restart", false, "restart a mission script from the beginning")
+	isActive := flags.Bool("active", true, "whether the script is active")
+	activationTime := flags.Uint("activation-time", 0, "game time (in milliseconds) at which the script next runs")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <input save> <output save> <script name or #index>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 3 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(flags.Arg(0))
+	theScript := saveFile.Scripts.ScriptAt(findScript(&saveFile, flags.Arg(2)))
+
+	check := func(err error) {
+		if err != nil {
+			fmt.Printf("Unable to change '%s': %v\n", theScript.Name, err)
+			os.Exit(1)
+		}
+	}
+
+	// Only change the values that were given.
+	flags.Visit(func(given *flag.Flag) {
+		switch given.Name {
+		case "timera", "timerb":
+			timer, value := 0, *timerA
+
+			if given.Name == "timerb" {
+				timer, value = 1, *timerB
+			}
+
+			if value < math.MinInt32 || value > math.MaxInt32 {
+				check(fmt.Errorf("timer value %d doesn't fit in a script variable", value))
+			}
+
+			check(theScript.SetTimer(timer, save.IntValue(int32(value))))
+		case "ip":
+			if *offset > math.MaxUint32 {
+				check(fmt.Errorf("offset %d is too large", *offset))
+			}
+
+			theScript.Jump(uint32(*offset))
+		case "restart":
+			if *restart {
+				check(theScript.RestartMission())
+			}
+		case "active":
+			if *isActive {
+				theScript.Resume()
+			} else {
+				check(theScript.Pause())
+			}
+		case "activation-time":
+			if *activationTime > math.MaxUint32 {
+				check(fmt.Errorf("activation time %d is too large", *activationTime))
+			}
+
+			check(theScript.SetActivationTime(uint32(*activationTime)))
+		}
+	})
+
+	for index, value := range locals {
+		check(theScript.SetLocal(index, value))
+	}
+
+	fmt.Printf("Updated '%s'.\n", theScript.Name)
+	writeSave(flags.Arg(1), &saveFile)
+}
+
+// Pauses, resumes, delays or removes a script.
+func changeScript(name string, action string, arguments []string, usage func()) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = usage
+	relink := new(bool)
+
+	if action == "remove" {
+		relink = addRelinkFlag(flags)
+	}
+
+	flags.Parse(arguments)
+	arguments = flags.Args()
+
+	expectedCount := 3
+
+	if action == "delay" {
+		expectedCount = 4
+	}
+
+	if len(arguments) != expectedCount {
+		usage()
+		os.Exit(1)
+	}
+
+	saveFile := loadSave(arguments[0])
+	scripts := &saveFile.Scripts
+
+	if *relink {
+		scripts.AllowRelinkingCorruptList()
+	}
+
+	index := findScript(&saveFile, arguments[2])
+	theScript := scripts.ScriptAt(index)
+	scriptName := theScript.Name
+
+	var err error
+
+	switch action {
+	case "pause":
+		err = theScript.Pause()
+
+	case "resume":
+		theScript.Resume()
+
+	case "delay":
+		milliseconds, parseErr := strconv.ParseUint(arguments[3], 10, 32)
+
+		if parseErr != nil {
+			fmt.Printf("Bad delay '%s'.\n", arguments[3])
+			os.Exit(1)
+		}
+
+		err = theScript.Delay(&saveFile.Vars, uint32(milliseconds))
+
+	case "remove":
+		if theScript.IsMissionScript() {
+			fmt.Println("Warning: Removing a mission script. The game will still think a mission is running.")
+		}
+
+		err = scripts.RemoveScript(index)
+	}
+
+	if err != nil {
+		fmt.Printf("Unable to %s '%s': %v\n", action, scriptName, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Done: %s '%s'.\n", action, scriptName)
+	writeSave(arguments[1], &saveFile)
+}
+
+func scriptsCommand(name string, arguments []string) {
+	usage := func() {
+		fmt.Printf("Usage: '%s set [options] <input save> <output save> <script name or #index>'\n", name)
+		fmt.Printf("   or: '%s pause|resume <input save> <output save> <script name or #index>'\n", name)
+		fmt.Printf("   or: '%s remove [-relink] <input save> <output save> <script name or #index>'\n", name)
+		fmt.Printf("   or: '%s delay <input save> <output save> <script name or #index> <milliseconds>'\n", name)
+	}
+
+	if len(arguments) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch arguments[0] {
+	case "set":
+		setScriptValues(name+" set", arguments[1:])
+
+	case "pause", "resume", "delay", "remove":
+		changeScript(name+" "+arguments[0], arguments[0], arguments[1:], usage)
+
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"gta_save/save"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A save in a timeline, along with the game time at which it was made.
+type timelineSave struct {
+	name    string
+	time    uint32
+	globals []uint32
+	file    save.File
+}
+
+// The values of one global across every save in a timeline.
+type globalTimeline struct {
+	symbol save.GlobalSymbol
+	named  bool
+	kind   string
+	values []uint32
+}
+
+// Reads every save (".b" file) in `directory`, ordered by the game time at which the saves
+// were made.
+func readTimelineSaves(directory string) []timelineSave {
+	entries, err := os.ReadDir(directory)
+
+	if err != nil {
+		fmt.Printf("Unable to read '%s': %v\n", directory, err)
+		os.Exit(1)
+	}
+
+	saves := []timelineSave{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".b") {
+			continue
+		}
+
+		savePath := filepath.Join(directory, entry.Name())
+		saveFile, err := readSaveChecked(savePath)
+
+		// One broken file shouldn't stop the rest of the timeline from being read.
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Skipping '%s': %v\n", savePath, err)
+			continue
+		}
+
+		saves = append(saves, timelineSave{
+			name:    entry.Name(),
+			time:    saveFile.Vars.TimeMapping.TimeInMilliseconds,
+			globals: saveFile.Scripts.GlobalVariables(),
+			file:    saveFile,
+		})
+	}
+
+	sort.SliceStable(saves, func(i, j int) bool {
+		return saves[i].time < saves[j].time
+	})
+
+	return saves
+}
+
+// Returns true if `value` is more likely to be a float than an integer. Small integers have
+// tiny float values, and most floats have huge integer values.
+func looksLikeFloat(value uint32) bool {
+	floating := math.Abs(float64(math.Float32frombits(value)))
+	return 1e-4 <= floating && floating <= 1e7
+}
+
+// Works out whether the values look like a flag, a counter, a float or none of those.
+func classifyValues(values []uint32) string {
+	isFlag, isCounter, isFloat := true, true, true
+
+	for i, value := range values {
+		if value != 0 && value != 1 {
+			isFlag = false
+		}
+
+		if value != 0 && !looksLikeFloat(value) {
+			isFloat = false
+		}
+
+		if i > 0 && int32(value) < int32(values[i-1]) {
+			isCounter = false
+		}
+	}
+
+	switch {
+	case isFlag:
+		return "flag"
+	case isFloat:
+		return "float"
+	case isCounter:
+		return "counter"
+	}
+
+	return "value"
+}
+
+func (timeline *globalTimeline) format(value uint32) string {
+	if timeline.named {
+		return timeline.symbol.Format(value)
+	}
+
+	if timeline.kind == "float" {
+		return strconv.FormatFloat(float64(math.Float32frombits(value)), 'g', -1, 32)
+	}
+
+	return strconv.Itoa(int(int32(value)))
+}
+
+// Builds a timeline for every global that changes between the saves, ignoring globals from
+// `ignoreFrom` onwards and any globals that not every save has.
+func buildTimelines(saves []timelineSave, symbols *save.SymbolMap, ignoreFrom int) []globalTimeline {
+	limit := ignoreFrom
+
+	for _, timelineSave := range saves {
+		if len(timelineSave.globals) < limit {
+			limit = len(timelineSave.globals)
+		}
+	}
+
+	timelines := []globalTimeline{}
+
+	for index := save.FirstGlobalVariable; index < limit; index++ {
+		values := make([]uint32, len(saves))
+		changes := false
+
+		for i, timelineSave := range saves {
+			values[i] = timelineSave.globals[index]
+			changes = changes || values[i] != values[0]
+		}
+
+		if !changes {
+			continue
+		}
+
+		timeline := globalTimeline{values: values, kind: classifyValues(values)}
+		timeline.symbol, timeline.named = symbols.AtIndex(index)
+
+		if !timeline.named {
+			timeline.symbol = save.GlobalSymbol{Name: fmt.Sprintf("$%d", index), Index: index}
+		} else if timeline.symbol.Type == save.GlobalFloat {
+			timeline.kind = "float"
+		}
+
+		timelines = append(timelines, timeline)
+	}
+
+	return timelines
+}
+
+// Writes one row per global, with a column for each save.
+func writeTimelineCsv(path string, saves []timelineSave, timelines []globalTimeline) error {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	csvWriter := csv.NewWriter(file)
+	header := []string{"index", "name", "kind"}
+
+	for _, timelineSave := range saves {
+		header = append(header, fmt.Sprintf("%s (%d ms)", timelineSave.name, timelineSave.time))
+	}
+
+	csvWriter.Write(header)
+
+	for i := range timelines {
+		timeline := &timelines[i]
+		name := ""
+
+		if timeline.named {
+			name = timeline.symbol.Name
+		}
+
+		row := []string{strconv.Itoa(timeline.symbol.Index), name, timeline.kind}
+
+		for _, value := range timeline.values {
+			row = append(row, timeline.format(value))
+		}
+
+		csvWriter.Write(row)
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// Prints the timelines grouped by kind, showing each value only when it changes.
+func printTimelineSummary(timelines []globalTimeline) {
+	const maxShownValues = 8
+
+	groups := []struct {
+		kind    string
+		heading string
+	}{
+		{"counter", "Counters"},
+		{"flag", "Flags"},
+		{"float", "Floats"},
+		{"value", "Other values"},
+	}
+
+	for _, group := range groups {
+		var lines []string
+
+		for i := range timelines {
+			timeline := &timelines[i]
+
+			if timeline.kind != group.kind {
+				continue
+			}
+
+			shown := []string{}
+
+			for j, value := range timeline.values {
+				if j == 0 || value != timeline.values[j-1] {
+					shown = append(shown, timeline.format(value))
+				}
+			}
+
+			if len(shown) > maxShownValues {
+				shown = append(shown[:maxShownValues-1], "...", shown[len(shown)-1])
+			}
+
+			name := fmt.Sprintf("$%d", timeline.symbol.Index)
+
+			if timeline.named {
+				name = fmt.Sprintf("%s (%s)", timeline.symbol.Name, name)
+			}
+
+			lines = append(lines, fmt.Sprintf("  %s: %s", name, strings.Join(shown, " -> ")))
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Printf("\n%s (%d):\n%s\n", group.heading, len(lines), strings.Join(lines, "\n"))
+	}
+}
+
+func globalsTimeline(name string, arguments []string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	symbols := save.NewSymbolMap()
+	addSymbolsFlag(flags, symbols)
+
+	ignoreFrom, mainPath := addComparisonFlags(flags)
+	csvPath := flags.String("o", "", "also write the timeline to this CSV file")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: '%s [options] <directory of saves>'\n", name)
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(arguments)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	saves := readTimelineSaves(flags.Arg(0))
+
+	if len(saves) < 2 {
+		fmt.Printf("Found %d saves in '%s', but at least two are needed.\n", len(saves), flags.Arg(0))
+		os.Exit(1)
+	}
+
+	saveFiles := make([]*save.File, len(saves))
+
+	for i := range saves {
+		saveFiles[i] = &saves[i].file
+	}
+
+	timelines := buildTimelines(saves, symbols, comparisonLimit(*ignoreFrom, *mainPath, saveFiles))
+
+	fmt.Printf("%d saves, from %d ms to %d ms. %d globals change.\n",
+		len(saves), saves[0].time, saves[len(saves)-1].time, len(timelines))
+
+	printTimelineSummary(timelines)
+
+	if *csvPath != "" {
+		if err := writeTimelineCsv(*csvPath, saves, timelines); err != nil {
+			fmt.Printf("Unable to write '%s': %v\n", *csvPath, err)
+			os.Exit(1)
+		}
+	}
+}